@@ -1,6 +1,6 @@
 from textual.app import App, ComposeResult
 from textual.containers import Horizontal, Vertical, VerticalScroll, VerticalGroup, HorizontalGroup
-from textual.widgets import Button, RichLog, Log, Label, Footer, Input, Static, Switch
+from textual.widgets import Button, RichLog, Log, Label, Footer, Input, Static, Switch, Select
 from textual.binding import Binding
 from textual.worker import get_current_worker
 import time, datetime
@@ -9,9 +9,17 @@ import redis
 import asyncio
 import threading
 import json
+import uuid
+import os
 from redis.asyncio import Redis as AsyncRedis 
 
 CHANNEL = "MODULE_Q"
+# Must match the module: "pubsub" publishes on CMD_Q, "streams" XADDs to CMD_STREAM
+CMD_INTAKE = os.getenv("MODULE_INTAKE", "pubsub")
+CMD_STREAM = "CMD_STREAM"
+# Capped stream of everything the module sent, replayed on connect
+HISTORY_STREAM = "MODULE_HISTORY"
+HISTORY_BACKFILL_S = 600
 class HostGUI(App):
     CSS = """
     Button {
@@ -59,6 +67,15 @@ class HostGUI(App):
     r = redis.Redis(host='localhost', port=6379, db=0)
 
     heartbeat_skip_count = None
+    heartbeat_seq = 0
+
+    # Module heartbeat watch, same rule as the module: lost after 3 missed beats
+    MODULE_BEAT_KEY = "heartbeat:latest"
+    MODULE_BEAT_INTERVAL = 0.5
+    MODULE_MISSED_LIMIT = 3
+    module_beat_seq = None
+    module_beat_at = None
+    module_lost = False
 
     cmd_counter = 0
     cmd_payload = {
@@ -68,14 +85,44 @@ class HostGUI(App):
         "CMD_HASH": "23f451"
         }
     
-    def _create_command(self, CMD="INSPECT_PANEL"):
+    def _create_command(self, CMD="INSPECT_PANEL", args=None):
         cmd_payload = self.cmd_payload
         self.cmd_counter += 1
         cmd_payload["CMD_COUNTER"] = self.cmd_counter
         cmd_payload["CMD"] = CMD
+        cmd_payload.pop("msg_id", None)
+        cmd_payload.pop("args", None)
         cmd_payload["CMD_HASH"] = str(hash(frozenset(cmd_payload.items())))
+        # Every reply from the module echoes this msg_id
+        cmd_payload["msg_id"] = str(uuid.uuid4())
+        if args is not None:
+            cmd_payload["args"] = args
         return cmd_payload
 
+    def _send_command(self, json_payload):
+        if CMD_INTAKE == "streams":
+            self.r.xadd(CMD_STREAM, {"payload": json_payload})
+        else:
+            self.r.publish("CMD_Q", json_payload)
+
+    def _fault_args(self):
+        """Named fault from the fault inputs (module default value when empty)."""
+        args = {"fault": self.query_one("#fault_select", Select).value}
+        value = self.query_one("#fault_value", Input)
+        if value.value:
+            args["value"] = float(value.value)
+        duration = self.query_one("#fault_duration", Input)
+        args["duration_s"] = float(duration.value or duration.placeholder)
+        return args
+
+    def _thrust_args(self):
+        """Delta-v (cm/s) from the X/Y/Z inputs (placeholder used when empty)."""
+        args = {}
+        for axis in ("x", "y", "z"):
+            box = self.query_one(f"#thrust_{axis}", Input)
+            args[axis] = int(box.value or box.placeholder)
+        return args
+
     # ---------- Redis async subscriber (non-blocking) ----------
     async def _parse_redis_message(self, msg: str):
         
@@ -86,19 +133,26 @@ class HostGUI(App):
             data = json.loads(msg)
 
             self.output_widget.update(json.dumps(data, indent=1))
-            # Work on the parsed redis message here to show outputs?
-            # There should be a key called "return_params" (list)
-            # Also a key called "type" = "RET_VALUE" for output packets
-
-
-            if data.get("type") == "RET_VALUE":
-                # Clear return pane
-                self.return_widget.clear()
+            # Replies to commands have "type" = "REPLY" and a "status"
+            # (ACK/ACCEPTED/REJECTED/PROGRESS/RESULT/ERROR), see module/protocol
+
+            if data.get("type") == "REPLY":
+                status = data.get("status", "")
+                if status == "ACK":
+                    # New command starting, clear return pane
+                    self.return_widget.clear()
                 self.return_widget.write(f"{datetime.datetime.now(timezone.utc).strftime('%Y-%m-%d %H:%M:%S')} - RX")
-                return_params = data.get("return_params", [])
 
-                for param in return_params:
-                    self.return_widget.write(f"[green]Ret: {param}[/green]") ###################
+                colour = "red" if status in ("REJECTED", "ERROR") else "green"
+                line = f"[{colour}]{data.get('cmd', '')} {status} msg_id={data.get('msg_id', '')}"
+                if data.get("reason"):
+                    line += f" ({data['reason']})"
+                if data.get("dup"):
+                    line += " DUPLICATE"
+                self.return_widget.write(line + f"[/{colour}]")
+
+                for key, value in (data.get("data") or {}).items():
+                    self.return_widget.write(f"[green]Ret: {key} = {value}[/green]")
 
             # TODO: Thrust
             # TODO: Image URI etc.
@@ -120,12 +174,29 @@ class HostGUI(App):
         except json.JSONDecodeError:
             self.log_widget.write(f"[red]Failed to decode message: {msg}[/red]")
 
+    async def _backfill_history(self, r):
+        """Show what the module sent in the last HISTORY_BACKFILL_S before we connected."""
+        since_ms = int((time.time() - HISTORY_BACKFILL_S) * 1000)
+        try:
+            entries = await r.xrange(HISTORY_STREAM, min=str(since_ms), max="+")
+        except Exception as e:
+            self.log_widget.write(f"[red]No module history: {e}[/red]")
+            return
+        self.log_widget.write(f"[green]History: {len(entries)} entries from the last {HISTORY_BACKFILL_S}s[/green]")
+        for entry_id, fields in entries:
+            fields = {k.decode(): v.decode() for k, v in fields.items()}
+            at = datetime.datetime.fromtimestamp(int(entry_id.decode().split("-")[0]) / 1000, timezone.utc)
+            line = f"{at.strftime('%H:%M:%S')} {fields.get('kind', '')} {fields.get('status', '')} msg_id={fields.get('msg_id', '')}"
+            self.log_widget.write(f"[dim]History: {line}[/dim]")
+
     async def _redis_subscriber(self):
         """Async Redis pub/sub loop; runs as a Textual worker."""
         self.log_widget.write(f"[green]Starting Redis subscriber to {CHANNEL}[/green]")
         r = AsyncRedis(host="localhost", port=6379, db=0) #decode_responses=True)
         pubsub = r.pubsub()
         await pubsub.subscribe("CMD_Q", "MODULE_Q") # CHANNEL)
+        # Subscribed first so nothing falls between the backfill and live messages
+        await self._backfill_history(r)
 
         worker = get_current_worker()
         try:
@@ -165,8 +236,11 @@ class HostGUI(App):
 
     def heartbeat(self) -> None:
         log = self.query_one("#log", RichLog)
-        tstamp = time.gmtime() # USE GMT / UTC time since this is a spacecraft
-        tstamp = datetime.datetime(*tstamp[:7])
+        # USE GMT / UTC time since this is a spacecraft, with sub-second
+        # resolution so consecutive beats never repeat a timestamp
+        tstamp = datetime.datetime.now(timezone.utc)
+        # Skipped beats still use up a seq number, the module sees the gap
+        self.heartbeat_seq += 1
 
         #log.write(r"[cyan]HOST[/cyan] Heartbeat "+f" [white]{tstamp.isoformat()}[/white]")
 
@@ -178,8 +252,9 @@ class HostGUI(App):
             num_skip_beat = int(num_skip_beat)
         if not skip_beat.value:
             # SEND HEARTBEAT
-            log.write(r"[cyan]HOST[/cyan] Heartbeat "+f" [white]{tstamp.isoformat()}[/white]")
-            self.r.lpush("HOST_HEARTBEAT", f"{tstamp.isoformat()}")
+            log.write(r"[cyan]HOST[/cyan] Heartbeat "+f" [white]#{self.heartbeat_seq} {tstamp.isoformat()}[/white]")
+            beat = {"seq": self.heartbeat_seq, "ts": tstamp.isoformat().replace("+00:00", "Z")}
+            self.r.lpush("HOST_HEARTBEAT", json.dumps(beat))
         else:
             self.log_widget.write(f"[green]Skipping Heartbeat as per user request - {self.heartbeat_skip_count} [/green]")
             if self.heartbeat_skip_count is None:
@@ -190,6 +265,32 @@ class HostGUI(App):
                 self.heartbeat_skip_count = None
                 skip_beat.value = False
 
+    def watch_module(self) -> None:
+        """Host side of the module heartbeat: declare the module lost when no
+        new seq arrived for MODULE_MISSED_LIMIT beats (or the key expired)."""
+        now = time.monotonic()
+        raw = self.r.get(self.MODULE_BEAT_KEY)
+        if raw is not None:
+            try:
+                beat = json.loads(raw)
+            except json.JSONDecodeError:
+                beat = {}
+            if beat.get("seq") is not None and beat.get("seq") != self.module_beat_seq:
+                self.module_beat_seq = beat["seq"]
+                self.module_beat_at = now
+                self.host_debug_widget.update(
+                    f"MODULE #{beat['seq']} {beat.get('status', '')} up {beat.get('uptime_s', 0):.0f}s"
+                )
+        since = now - self.module_beat_at if self.module_beat_at is not None else None
+        missed = 0 if since is None else max(int(since / self.MODULE_BEAT_INTERVAL) - 1, 0)
+        lost = self.module_beat_at is None or missed >= self.MODULE_MISSED_LIMIT
+        if lost and not self.module_lost:
+            self.log_widget.write(f"[red]MODULE LOST: no module heartbeat ({missed} beats missed)[/red]")
+            self.host_debug_widget.update("[red]MODULE LOST[/red]")
+        elif not lost and self.module_lost:
+            self.log_widget.write(f"[green]MODULE RESTORED: seq {self.module_beat_seq}[/green]")
+        self.module_lost = lost
+
     async def on_mount(self) -> None:
         # Cache widget refs once
         self.log_widget = self.query_one("#log", RichLog)
@@ -201,6 +302,9 @@ class HostGUI(App):
         # Schedule the timer: run every 0.5 seconds
         self.set_interval(0.5, self.heartbeat)
 
+        # Watch the module heartbeat at twice its rate
+        self.set_interval(self.MODULE_BEAT_INTERVAL / 2, self.watch_module)
+
         # Schedule the cleanup: run every 15 seconds
         self.set_interval(15, self.cleanup)
 
@@ -221,15 +325,19 @@ class HostGUI(App):
                 yield Button("INSPECT_PANEL", id="INSPECT_PANEL")
                 yield Button("PERFORM_MANEUVER", id="PERFORM_MANEUVER")
                 with HorizontalGroup():
-                    yield Label("X")
-                    yield Input(placeholder="255", type="integer")
+                    yield Label("dVx cm/s")
+                    yield Input(placeholder="255", type="integer", id="thrust_x")
+                with HorizontalGroup():
+                    yield Label("dVy cm/s")
+                    yield Input(placeholder="100", type="integer", id="thrust_y")
                 with HorizontalGroup():
-                    yield Label("Y")
-                    yield Input(placeholder="100", type="integer")
+                    yield Label("dVz cm/s")
+                    yield Input(placeholder="155", type="integer", id="thrust_z")
                 with HorizontalGroup():
-                    yield Label("Z")
-                    yield Input(placeholder="155", type="integer")
+                    yield Label("Thrust Inhibit:")
+                    yield Switch(animate=True, value=False, id="thrust_inhibit_switch")
                 yield Button("HEALTH_CHECK", id="HEALTH_CHECK")
+                yield Button("ABORT_ALL", id="ABORT_ALL")
 
                 # Middle pane with Switch
                 with VerticalGroup(classes="pane"):
@@ -241,7 +349,18 @@ class HostGUI(App):
                         with HorizontalGroup():
                             yield Label("N Beats:")
                             yield Input(placeholder="5", value="5", type="integer", id="n_beats_input")
+                    yield Select(
+                        [(f, f) for f in ("BROWNOUT", "OVERTEMP", "PAUSE_HEARTBEAT", "DROP_REPLIES", "DELAY_REPLIES")],
+                        value="BROWNOUT", allow_blank=False, id="fault_select",
+                    )
+                    with HorizontalGroup():
+                        yield Label("Value:")
+                        yield Input(placeholder="default", type="number", id="fault_value")
+                    with HorizontalGroup():
+                        yield Label("Duration s:")
+                        yield Input(placeholder="30", type="number", id="fault_duration")
                     yield Button("Inject Fault", id="INJECT_FAULT")
+                    yield Button("Clear Faults", id="CLEAR_FAULT")
                     yield Button("Resume", id="RESUME")
                     yield Button("Heat and Clear", id="HEAT_AND_CLEAR")
 
@@ -255,6 +374,18 @@ class HostGUI(App):
                 yield Label(classes="pane_small", id="host_debug")
             yield Footer()
 
+    def on_switch_changed(self, event: Switch.Changed) -> None:
+        if event.switch.id != "thrust_inhibit_switch":
+            return
+        log = self.query_one("#log", RichLog)
+        self.host_debug_widget.update(f"THRUST_INHIBIT set to {event.value}")
+
+        # Send a Redis Subsciption to the CMD_Q pubsub"
+        cmd = self._create_command("SET_THRUST_INHIBIT", {"inhibit": event.value})
+        json_payload = json.dumps(cmd)
+        self._send_command(json_payload)
+        log.write(f"[green] Starting Command: Set Thrust Inhibit {event.value} [/green]")
+
     def on_button_pressed(self, event: Button.Pressed) -> None:
         log = self.query_one("#log", RichLog)
         status = self.query_one("#debug", Label)
@@ -267,16 +398,16 @@ class HostGUI(App):
             # Send a Redis Subsciption to the CMD_Q pubsub"
             cmd = self._create_command("INSPECT_PANEL")
             json_payload = json.dumps(cmd)
-            self.r.publish("CMD_Q",json_payload)
+            self._send_command(json_payload)
             log.write("[green] Starting Command: Inspect Panel [/green]")
 
         elif event.button.id == "PERFORM_MANEUVER":
             log.write("[red]Perform Maneuver button pressed![/red]")
 
             # Send a Redis Subsciption to the CMD_Q pubsub"
-            cmd = self._create_command("PERFORM_MANEUVER")
+            cmd = self._create_command("PERFORM_MANEUVER", self._thrust_args())
             json_payload = json.dumps(cmd)
-            self.r.publish("CMD_Q",json_payload)
+            self._send_command(json_payload)
             log.write("[green] Starting Command: Perform Maneuver [/green]")
 
         elif event.button.id == "HEALTH_CHECK":
@@ -286,19 +417,39 @@ class HostGUI(App):
             # Send a Redis Subsciption to the CMD_Q pubsub"
             cmd = self._create_command("HEALTH_CHECK")
             json_payload = json.dumps(cmd)
-            self.r.publish("CMD_Q",json_payload)
+            self._send_command(json_payload)
             log.write("[green] Starting Command: Health Check [/green]")
 
+        elif event.button.id == "ABORT_ALL":
+            self.host_debug_widget.update("ABORT_ALL clicked")
+            log.write("[red]Abort All button pressed![/red]")
+
+            # Send a Redis Subsciption to the CMD_Q pubsub"
+            cmd = self._create_command("ABORT_ALL")
+            json_payload = json.dumps(cmd)
+            self._send_command(json_payload)
+            log.write("[green] Starting Command: Abort All [/green]")
+
         elif event.button.id == "INJECT_FAULT":
             self.host_debug_widget.update("INJECT_FAULT clicked")
             log.write("[red]Inject Fault button pressed![/red]")
 
             # Send a Redis Subsciption to the CMD_Q pubsub"
-            cmd = self._create_command("INJECT_FAULT")
+            cmd = self._create_command("INJECT_FAULT", self._fault_args())
             json_payload = json.dumps(cmd)
-            self.r.publish("CMD_Q",json_payload)
+            self._send_command(json_payload)
             log.write("[green] Starting Command: Inject Fault [/green]")
         
+        elif event.button.id == "CLEAR_FAULT":
+            self.host_debug_widget.update("CLEAR_FAULT clicked")
+            log.write("[red]Clear Faults button pressed![/red]")
+
+            # Send a Redis Subsciption to the CMD_Q pubsub"
+            cmd = self._create_command("CLEAR_FAULT")
+            json_payload = json.dumps(cmd)
+            self._send_command(json_payload)
+            log.write("[green] Starting Command: Clear Faults [/green]")
+
         elif event.button.id == "RESUME":
             self.host_debug_widget.update("RESUME clicked")
             log.write("[red]Resume button pressed![/red]")
@@ -306,7 +457,7 @@ class HostGUI(App):
             # Send a Redis Subsciption to the CMD_Q pubsub"
             cmd = self._create_command("RESUME")
             json_payload = json.dumps(cmd)
-            self.r.publish("CMD_Q",json_payload)
+            self._send_command(json_payload)
             log.write("[green] Starting Command: Resume [/green]")
 
         elif event.button.id == "HEAT_AND_CLEAR":
@@ -316,7 +467,7 @@ class HostGUI(App):
             # Send a Redis Subsciption to the CMD_Q pubsub"
             cmd = self._create_command("HEAT_AND_CLEAR")
             json_payload = json.dumps(cmd)
-            self.r.publish("CMD_Q",json_payload)
+            self._send_command(json_payload)
             log.write("[green] Starting Command: Heat and Clear [/green]")
 
 
@@ -0,0 +1,150 @@
+package codec
+
+//go:generate protoc -I .. --go_out=.. --go_opt=module=communication_module ../proto/module.proto
+
+import (
+	"communication_module/command"
+	"communication_module/protocol"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Wire encoding of commands, replies and events. JSON is the default, the
+// protobuf encoding (proto/module.proto) is the compact one for slow links.
+// The encoding is chosen per channel, see Channels.
+
+type Encoding string
+
+const (
+	JSON     Encoding = "json"
+	PROTOBUF Encoding = "protobuf"
+)
+
+func ParseEncoding(s string) (Encoding, error) {
+	switch enc := Encoding(strings.ToLower(strings.TrimSpace(s))); enc {
+	case JSON, PROTOBUF:
+		return enc, nil
+	}
+	return "", fmt.Errorf("unknown encoding %q (json, protobuf)", s)
+}
+
+// EncodeReply stamps the reply (see Reply.Stamp) and encodes it
+func EncodeReply(enc Encoding, r protocol.Reply) ([]byte, error) {
+	switch enc {
+	case JSON:
+		return protocol.MarshalReply(r)
+	case PROTOBUF:
+		return marshalReply(r.Stamp())
+	}
+	return nil, fmt.Errorf("unknown encoding %q", enc)
+}
+
+func DecodeReply(enc Encoding, data []byte) (protocol.Reply, error) {
+	switch enc {
+	case JSON:
+		return protocol.UnmarshalReply(data)
+	case PROTOBUF:
+		return unmarshalReply(data)
+	}
+	return protocol.Reply{}, fmt.Errorf("unknown encoding %q", enc)
+}
+
+// EncodeEvent stamps the event (see Event.Stamp) and encodes it
+func EncodeEvent(enc Encoding, e protocol.Event) ([]byte, error) {
+	switch enc {
+	case JSON:
+		return protocol.MarshalEvent(e)
+	case PROTOBUF:
+		return marshalEvent(e.Stamp())
+	}
+	return nil, fmt.Errorf("unknown encoding %q", enc)
+}
+
+func DecodeEvent(enc Encoding, data []byte) (protocol.Event, error) {
+	switch enc {
+	case JSON:
+		return protocol.UnmarshalEvent(data)
+	case PROTOBUF:
+		return unmarshalEvent(data)
+	}
+	return protocol.Event{}, fmt.Errorf("unknown encoding %q", enc)
+}
+
+// EncodeCommand is the host side of DecodeCommand
+func EncodeCommand(enc Encoding, c command.Command) ([]byte, error) {
+	switch enc {
+	case JSON:
+		return jsonCommand(c)
+	case PROTOBUF:
+		return marshalCommand(c)
+	}
+	return nil, fmt.Errorf("unknown encoding %q", enc)
+}
+
+// DecodeCommand decodes and normalizes a command like command.ParseCommand
+func DecodeCommand(enc Encoding, data []byte) (command.Command, error) {
+	switch enc {
+	case JSON:
+		return command.ParseCommand(string(data))
+	case PROTOBUF:
+		c, err := unmarshalCommand(data)
+		if err != nil {
+			return c, fmt.Errorf("malformed payload: %w", err)
+		}
+		return c.Normalize()
+	}
+	return command.Command{}, fmt.Errorf("unknown encoding %q", enc)
+}
+
+// Channels maps a channel (or stream) to its encoding, others are JSON
+type Channels map[string]Encoding
+
+// ParseChannels reads "MODULE_Q=protobuf,CMD_Q=json"
+func ParseChannels(spec string) (Channels, error) {
+	channels := Channels{}
+	for _, part := range strings.Split(spec, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		channel, name, ok := strings.Cut(part, "=")
+		if !ok || strings.TrimSpace(channel) == "" {
+			return nil, fmt.Errorf("encoding %q is not CHANNEL=ENCODING", part)
+		}
+		enc, err := ParseEncoding(name)
+		if err != nil {
+			return nil, fmt.Errorf("channel %s: %w", channel, err)
+		}
+		channels[strings.TrimSpace(channel)] = enc
+	}
+	return channels, nil
+}
+
+// ChannelsFromEnv reads MODULE_ENCODING, see ParseChannels
+func ChannelsFromEnv() (Channels, error) {
+	channels, err := ParseChannels(os.Getenv("MODULE_ENCODING"))
+	if err != nil {
+		return nil, fmt.Errorf("MODULE_ENCODING: %w", err)
+	}
+	return channels, nil
+}
+
+// Encoding of channel
+func (c Channels) Encoding(channel string) Encoding {
+	if enc, ok := c[channel]; ok {
+		return enc
+	}
+	return JSON
+}
+
+func (c Channels) EncodeReply(channel string, r protocol.Reply) ([]byte, error) {
+	return EncodeReply(c.Encoding(channel), r)
+}
+
+func (c Channels) EncodeEvent(channel string, e protocol.Event) ([]byte, error) {
+	return EncodeEvent(c.Encoding(channel), e)
+}
+
+func (c Channels) DecodeCommand(channel, payload string) (command.Command, error) {
+	return DecodeCommand(c.Encoding(channel), []byte(payload))
+}
@@ -0,0 +1,384 @@
+package codec
+
+import (
+	"bytes"
+	"communication_module/command"
+	"communication_module/fault"
+	"communication_module/fsm"
+	"communication_module/proto/modulepb"
+	"communication_module/protocol"
+	"communication_module/state"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Conversion between the protocol types and proto/module.proto. Free form
+// maps (reply data, command args) are Structs holding what their JSON
+// holds, the system state is typed since it goes with every reply and event.
+
+func jsonCommand(c command.Command) ([]byte, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, fmt.Errorf("marshal command: %w", err)
+	}
+	return data, nil
+}
+
+func marshalCommand(c command.Command) ([]byte, error) {
+	pb, err := commandToProto(c)
+	if err != nil {
+		return nil, fmt.Errorf("marshal command: %w", err)
+	}
+	data, err := proto.Marshal(pb)
+	if err != nil {
+		return nil, fmt.Errorf("marshal command: %w", err)
+	}
+	return data, nil
+}
+
+func unmarshalCommand(data []byte) (command.Command, error) {
+	var pb modulepb.Command
+	if err := proto.Unmarshal(data, &pb); err != nil {
+		return command.Command{}, err
+	}
+	return commandFromProto(&pb)
+}
+
+func marshalReply(r protocol.Reply) ([]byte, error) {
+	pb := &modulepb.Reply{
+		MsgId:   r.MsgID,
+		Cmd:     r.Cmd,
+		Reason:  string(r.Reason),
+		Message: r.Message,
+		Dup:     r.Dup,
+		MsgTime: r.MsgTime,
+	}
+	var err error
+	if pb.Type, err = msgTypeToProto(r.Type); err != nil {
+		return nil, err
+	}
+	if pb.Status, err = enumToProto[modulepb.Status]("status", string(r.Status), modulepb.Status_value); err != nil {
+		return nil, err
+	}
+	if pb.Data, err = dataToProto(r.Data); err != nil {
+		return nil, err
+	}
+	if pb.SystemState, err = statusToProto(r.SystemState); err != nil {
+		return nil, err
+	}
+	data, err := proto.Marshal(pb)
+	if err != nil {
+		return nil, fmt.Errorf("marshal reply: %w", err)
+	}
+	return data, nil
+}
+
+func unmarshalReply(data []byte) (protocol.Reply, error) {
+	var pb modulepb.Reply
+	if err := proto.Unmarshal(data, &pb); err != nil {
+		return protocol.Reply{}, fmt.Errorf("unmarshal reply: %w", err)
+	}
+	r := protocol.Reply{
+		MsgID:   pb.MsgId,
+		Type:    msgTypeFromProto(pb.Type),
+		Cmd:     pb.Cmd,
+		Status:  protocol.Status(enumFromProto(int32(pb.Status), modulepb.Status_name)),
+		Reason:  protocol.Reason(pb.Reason),
+		Message: pb.Message,
+		Dup:     pb.Dup,
+		MsgTime: pb.MsgTime,
+	}
+	r.Data = dataFromProto(pb.Data)
+	var err error
+	if r.SystemState, err = statusFromProto(pb.SystemState); err != nil {
+		return r, fmt.Errorf("unmarshal reply: %w", err)
+	}
+	return r, nil
+}
+
+func marshalEvent(e protocol.Event) ([]byte, error) {
+	pb := &modulepb.Event{
+		MsgId:         e.MsgID,
+		Message:       e.Message,
+		CorrelationId: e.CorrelationID,
+		Reason:        string(e.Reason),
+		MsgTime:       e.MsgTime,
+	}
+	var err error
+	if pb.Type, err = msgTypeToProto(e.Type); err != nil {
+		return nil, err
+	}
+	if pb.Data, err = dataToProto(e.Data); err != nil {
+		return nil, err
+	}
+	if pb.SystemState, err = statusToProto(e.SystemState); err != nil {
+		return nil, err
+	}
+	data, err := proto.Marshal(pb)
+	if err != nil {
+		return nil, fmt.Errorf("marshal event: %w", err)
+	}
+	return data, nil
+}
+
+func unmarshalEvent(data []byte) (protocol.Event, error) {
+	var pb modulepb.Event
+	if err := proto.Unmarshal(data, &pb); err != nil {
+		return protocol.Event{}, fmt.Errorf("unmarshal event: %w", err)
+	}
+	e := protocol.Event{
+		MsgID:         pb.MsgId,
+		Type:          msgTypeFromProto(pb.Type),
+		Message:       pb.Message,
+		CorrelationID: pb.CorrelationId,
+		Reason:        protocol.Reason(pb.Reason),
+		MsgTime:       pb.MsgTime,
+	}
+	e.Data = dataFromProto(pb.Data)
+	var err error
+	if e.SystemState, err = statusFromProto(pb.SystemState); err != nil {
+		return e, fmt.Errorf("unmarshal event: %w", err)
+	}
+	return e, nil
+}
+
+// enumToProto maps a protocol string to its enum, "" is the zero value
+func enumToProto[E ~int32](field, s string, values map[string]int32) (E, error) {
+	if s == "" {
+		return 0, nil
+	}
+	v, ok := values[s]
+	if !ok || v == 0 {
+		return 0, fmt.Errorf("no protobuf %s for %q", field, s)
+	}
+	return E(v), nil
+}
+
+func enumFromProto(v int32, names map[int32]string) string {
+	if v == 0 {
+		return ""
+	}
+	return names[v]
+}
+
+func msgTypeToProto(t protocol.MsgType) (modulepb.MsgType, error) {
+	return enumToProto[modulepb.MsgType]("type", string(t), modulepb.MsgType_value)
+}
+
+func msgTypeFromProto(t modulepb.MsgType) protocol.MsgType {
+	return protocol.MsgType(enumFromProto(int32(t), modulepb.MsgType_name))
+}
+
+// dataToProto converts a data map to a Struct through its JSON, so values
+// (structs, slices ...) carry the same fields as in the JSON encoding. An
+// empty map is left out like in the JSON encoding.
+func dataToProto(data map[string]interface{}) (*structpb.Struct, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("marshal data: %w", err)
+	}
+	pb, err := objectToProto(b)
+	if err != nil {
+		return nil, fmt.Errorf("data: %w", err)
+	}
+	return pb, nil
+}
+
+func dataFromProto(pb *structpb.Struct) map[string]interface{} {
+	if len(pb.GetFields()) == 0 {
+		return nil
+	}
+	return pb.AsMap()
+}
+
+// objectToProto converts a JSON object to a Struct
+func objectToProto(b []byte) (*structpb.Struct, error) {
+	var m map[string]interface{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, errors.New("not a JSON object")
+	}
+	return structpb.NewStruct(m)
+}
+
+func commandToProto(c command.Command) (*modulepb.Command, error) {
+	pb := &modulepb.Command{
+		MsgId:      c.MSG_ID,
+		Cmd:        c.CMD,
+		CmdCounter: int64(c.CMD_COUNTER),
+		CmdHash:    c.CMD_HASH,
+	}
+	if len(c.ARGS) > 0 {
+		args, err := objectToProto(c.ARGS)
+		if err != nil {
+			return nil, fmt.Errorf("args: %w", err)
+		}
+		pb.Args = args
+	}
+	return pb, nil
+}
+
+func commandFromProto(pb *modulepb.Command) (command.Command, error) {
+	c := command.Command{
+		MSG_ID:      pb.GetMsgId(),
+		CMD:         pb.GetCmd(),
+		CMD_COUNTER: int(pb.GetCmdCounter()),
+		CMD_HASH:    pb.GetCmdHash(),
+	}
+	if pb.GetArgs() != nil {
+		args, err := json.Marshal(pb.GetArgs().AsMap())
+		if err != nil {
+			return c, fmt.Errorf("args: %w", err)
+		}
+		c.ARGS = args
+	}
+	return c, nil
+}
+
+// statusToProto converts a system state map (see ModuleState.Snapshot).
+// Keys that ModuleStatus has no field for are an error, not dropped.
+func statusToProto(m map[string]interface{}) (*modulepb.ModuleStatus, error) {
+	if m == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("system state: %w", err)
+	}
+	var s state.Snapshot
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&s); err != nil {
+		return nil, fmt.Errorf("system state: %w", err)
+	}
+
+	lastCommand, err := commandToProto(s.LastCommand)
+	if err != nil {
+		return nil, fmt.Errorf("system state: %w", err)
+	}
+	pb := &modulepb.ModuleStatus{
+		LastCommand:   lastCommand,
+		LastUpdated:   s.LastUpdated,
+		BatteryLevel:  s.BatteryLevel,
+		Temperature:   s.Temperature,
+		ThrustInhibit: s.ThrustInhibit,
+		PropellantKg:  s.PropellantKg,
+		Velocity:      vectorToProto(s.Velocity),
+		Position:      vectorToProto(s.Position),
+		CameraOn:      s.CameraOn,
+		HeaterOn:      s.HeaterOn,
+		VoltageV:      s.VoltageV,
+		CurrentA:      s.CurrentA,
+		SolarW:        s.SolarW,
+	}
+	if pb.Status, err = enumToProto[modulepb.State]("state", string(s.Status), modulepb.State_value); err != nil {
+		return nil, err
+	}
+	for _, f := range s.Faults {
+		pb.Faults = append(pb.Faults, &modulepb.Fault{
+			Kind:    string(f.Kind),
+			Value:   f.Value,
+			Started: timeToProto(f.Started),
+			Expires: timeToProto(f.Expires),
+		})
+	}
+	if s.Latch != nil {
+		pb.Latch = &modulepb.SafeLatch{
+			Cause: s.Latch.Cause,
+			Since: timeToProto(s.Latch.Since),
+			Also:  s.Latch.Also,
+		}
+		for _, kind := range s.Latch.Faults {
+			pb.Latch.Faults = append(pb.Latch.Faults, string(kind))
+		}
+	}
+	return pb, nil
+}
+
+func statusFromProto(pb *modulepb.ModuleStatus) (map[string]interface{}, error) {
+	if pb == nil {
+		return nil, nil
+	}
+	lastCommand, err := commandFromProto(pb.GetLastCommand())
+	if err != nil {
+		return nil, fmt.Errorf("system state: %w", err)
+	}
+	s := state.Snapshot{
+		Status: fsm.State(enumFromProto(int32(pb.Status), modulepb.State_name)),
+		Values: state.Values{
+			LastCommand:   lastCommand,
+			LastUpdated:   pb.LastUpdated,
+			BatteryLevel:  pb.BatteryLevel,
+			Temperature:   pb.Temperature,
+			ThrustInhibit: pb.ThrustInhibit,
+			PropellantKg:  pb.PropellantKg,
+			Velocity:      vectorFromProto(pb.Velocity),
+			Position:      vectorFromProto(pb.Position),
+			CameraOn:      pb.CameraOn,
+			HeaterOn:      pb.HeaterOn,
+			VoltageV:      pb.VoltageV,
+			CurrentA:      pb.CurrentA,
+			SolarW:        pb.SolarW,
+		},
+		// Never null in a snapshot
+		Faults: []fault.Fault{},
+	}
+	for _, f := range pb.Faults {
+		s.Faults = append(s.Faults, fault.Fault{
+			Kind:    fault.Kind(f.Kind),
+			Value:   f.Value,
+			Started: timeFromProto(f.Started),
+			Expires: timeFromProto(f.Expires),
+		})
+	}
+	if pb.Latch != nil {
+		s.Latch = &state.SafeLatch{
+			Cause: pb.Latch.Cause,
+			Since: timeFromProto(pb.Latch.Since),
+			Also:  pb.Latch.Also,
+		}
+		for _, kind := range pb.Latch.Faults {
+			s.Latch.Faults = append(s.Latch.Faults, fault.Kind(kind))
+		}
+	}
+	m := state.StructToMap(s)
+	if m == nil {
+		return nil, fmt.Errorf("system state: cannot convert %+v", s)
+	}
+	return m, nil
+}
+
+func vectorToProto(v command.Vector) *modulepb.Vector {
+	return &modulepb.Vector{X: v.X, Y: v.Y, Z: v.Z}
+}
+
+func vectorFromProto(pb *modulepb.Vector) command.Vector {
+	return command.Vector{X: pb.GetX(), Y: pb.GetY(), Z: pb.GetZ()}
+}
+
+// timeToProto leaves a zero time unset
+func timeToProto(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+// timeFromProto returns local time, like the time.Now() the module stamps with
+func timeFromProto(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime().Local()
+}
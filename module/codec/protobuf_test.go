@@ -0,0 +1,134 @@
+package codec
+
+import (
+	"communication_module/command"
+	"communication_module/fault"
+	"communication_module/fsm"
+	"communication_module/protocol"
+	"communication_module/state"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// sample is one message in both encodings
+type sample struct {
+	name   string
+	encode func(Encoding) ([]byte, error)
+	decode func(Encoding, []byte) (interface{}, error)
+}
+
+// TestRoundTrip encodes and decodes each sample in both encodings, the
+// protobuf encoding must not lose or change a field
+func TestRoundTrip(t *testing.T) {
+	ms := state.Initialize(state.DefaultConfig)
+	idle := ms.Snapshot()
+	ms.Faults().Inject(fault.DROP_REPLIES, 0.5, time.Minute)
+	ms.SetStatus(fsm.SAFE, string(protocol.HEARTBEAT_LOST))
+	ms.SetStatus(fsm.SAFE, string(protocol.OVERTEMP))
+	latched := ms.Snapshot()
+
+	cmd := command.Command{MSG_ID: "4f7c", CMD: "PERFORM_MANEUVER", CMD_COUNTER: 7, CMD_HASH: "23f451", ARGS: json.RawMessage(`{"x":120,"y":0,"z":-40}`)}
+	progress := protocol.NewReply(cmd.MSG_ID, cmd.CMD, protocol.PROGRESS, "Maneuver 40%")
+	progress.Data["progress"] = 40
+	progress.Data["velocity"] = command.Vector{X: 0.48, Y: 0, Z: -0.16}
+	progress.Data["checks"] = []string{"battery", "temp"}
+	progress.SystemState = idle
+	rejected := protocol.Reject(cmd.MSG_ID, cmd.CMD, protocol.MODULE_SAFE, "Module is SAFE (HEARTBEAT_LOST)")
+	rejected.Dup = true
+	rejected.SystemState = latched
+	status := protocol.NewEvent("STATUS", "")
+	status.SystemState = latched
+	transition := protocol.NewEvent("TRANSITION", cmd.MSG_ID)
+	transition.Data["from"] = fsm.IDLE
+	transition.Data["to"] = fsm.ACTIVE
+	bare := protocol.NewEvent("HOST_LINK_RESTORED", "")
+
+	tests := []sample{
+		commandSample("command", cmd),
+		commandSample("command with empty args", command.Command{MSG_ID: "5a02", CMD: "HEALTH_CHECK", ARGS: json.RawMessage(`{}`)}),
+		commandSample("command without args", command.Command{MSG_ID: "5a01", CMD: "HEALTH_CHECK"}),
+		replySample("reply PROGRESS", progress),
+		replySample("reply REJECTED, latched", rejected),
+		eventSample("event STATUS, latched", status),
+		eventSample("event TRANSITION", transition),
+		eventSample("event without state", bare),
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sizes := map[Encoding]int{}
+			decoded := map[Encoding]interface{}{}
+			for _, enc := range []Encoding{JSON, PROTOBUF} {
+				data, err := tt.encode(enc)
+				if err != nil {
+					t.Fatalf("%s encode: %v", enc, err)
+				}
+				sizes[enc] = len(data)
+				if decoded[enc], err = tt.decode(enc, data); err != nil {
+					t.Fatalf("%s decode: %v", enc, err)
+				}
+			}
+			if !reflect.DeepEqual(decoded[JSON], decoded[PROTOBUF]) {
+				j, _ := json.Marshal(decoded[JSON])
+				p, _ := json.Marshal(decoded[PROTOBUF])
+				t.Fatalf("decoded messages differ\n  json:     %s\n  protobuf: %s", j, p)
+			}
+			t.Logf("json %d B, protobuf %d B", sizes[JSON], sizes[PROTOBUF])
+		})
+	}
+}
+
+func TestEncodeCommandArgsNotObject(t *testing.T) {
+	tests := []struct {
+		name string
+		args string
+	}{
+		{"array", `[1,2]`},
+		{"number", `3`},
+		{"null", `null`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := command.Command{MSG_ID: "m", CMD: "PING", ARGS: json.RawMessage(tt.args)}
+			if _, err := EncodeCommand(PROTOBUF, c); err == nil {
+				t.Fatalf("args %s encoded", tt.args)
+			}
+		})
+	}
+}
+
+func commandSample(name string, c command.Command) sample {
+	return sample{
+		name:   name,
+		encode: func(enc Encoding) ([]byte, error) { return EncodeCommand(enc, c) },
+		decode: func(enc Encoding, data []byte) (interface{}, error) {
+			c, err := DecodeCommand(enc, data)
+			// Raw args compare as values, not bytes
+			var args interface{}
+			if len(c.ARGS) > 0 {
+				json.Unmarshal(c.ARGS, &args)
+			}
+			c.ARGS = nil
+			return []interface{}{c, args}, err
+		},
+	}
+}
+
+func replySample(name string, r protocol.Reply) sample {
+	r = r.Stamp()
+	return sample{
+		name:   name,
+		encode: func(enc Encoding) ([]byte, error) { return EncodeReply(enc, r) },
+		decode: func(enc Encoding, data []byte) (interface{}, error) { return DecodeReply(enc, data) },
+	}
+}
+
+func eventSample(name string, e protocol.Event) sample {
+	e = e.Stamp()
+	return sample{
+		name:   name,
+		encode: func(enc Encoding) ([]byte, error) { return EncodeEvent(enc, e) },
+		decode: func(enc Encoding, data []byte) (interface{}, error) { return DecodeEvent(enc, data) },
+	}
+}
@@ -0,0 +1,301 @@
+package command
+
+import (
+	"bytes"
+	"communication_module/fault"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Args is the typed argument payload of a command
+type Args interface {
+	Validate() error
+}
+
+// ArgError names the argument that failed validation
+type ArgError struct {
+	Field   string
+	Problem string
+}
+
+func (e *ArgError) Error() string {
+	return fmt.Sprintf("invalid argument %s: %s", e.Field, e.Problem)
+}
+
+// NoArgs is the schema of commands that take no arguments
+type NoArgs struct{}
+
+func (NoArgs) Validate() error { return nil }
+
+// AxisLimit bounds one axis of a vector argument (inclusive)
+type AxisLimit struct {
+	Min float64
+	Max float64
+}
+
+func (l AxisLimit) check(field string, v *float64) error {
+	if v == nil {
+		return &ArgError{Field: field, Problem: "missing"}
+	}
+	if math.IsNaN(*v) || math.IsInf(*v, 0) {
+		return &ArgError{Field: field, Problem: "not a finite number"}
+	}
+	if *v < l.Min || *v > l.Max {
+		return &ArgError{Field: field, Problem: fmt.Sprintf("%g outside [%g, %g]", *v, l.Min, l.Max)}
+	}
+	return nil
+}
+
+// Vector in the module body frame
+type Vector struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	Z float64 `json:"z"`
+}
+
+// Scale returns v multiplied by f
+func (v Vector) Scale(f float64) Vector {
+	return Vector{X: v.X * f, Y: v.Y * f, Z: v.Z * f}
+}
+
+// Add returns v + o
+func (v Vector) Add(o Vector) Vector {
+	return Vector{X: v.X + o.X, Y: v.Y + o.Y, Z: v.Z + o.Z}
+}
+
+// ManeuverLimits are the per axis delta-v limits of PERFORM_MANEUVER (cm/s)
+var ManeuverLimits = struct {
+	X, Y, Z AxisLimit
+}{
+	X: AxisLimit{Min: -255, Max: 255},
+	Y: AxisLimit{Min: -255, Max: 255},
+	Z: AxisLimit{Min: -255, Max: 255},
+}
+
+// ManeuverArgs is the schema of PERFORM_MANEUVER: a delta-v vector in cm/s.
+// Pointers so a missing axis can be told apart from a zero one.
+type ManeuverArgs struct {
+	X *float64 `json:"x"`
+	Y *float64 `json:"y"`
+	Z *float64 `json:"z"`
+}
+
+func (a ManeuverArgs) Validate() error {
+	if err := ManeuverLimits.X.check("x", a.X); err != nil {
+		return err
+	}
+	if err := ManeuverLimits.Y.check("y", a.Y); err != nil {
+		return err
+	}
+	if err := ManeuverLimits.Z.check("z", a.Z); err != nil {
+		return err
+	}
+	if *a.X == 0 && *a.Y == 0 && *a.Z == 0 {
+		return &ArgError{Field: "x,y,z", Problem: "zero delta-v vector"}
+	}
+	return nil
+}
+
+// Vector of a validated ManeuverArgs
+func (a ManeuverArgs) Vector() Vector {
+	return Vector{X: *a.X, Y: *a.Y, Z: *a.Z}
+}
+
+// InhibitArgs is the schema of SET_THRUST_INHIBIT
+type InhibitArgs struct {
+	Inhibit *bool `json:"inhibit"`
+}
+
+func (a InhibitArgs) Validate() error {
+	if a.Inhibit == nil {
+		return &ArgError{Field: "inhibit", Problem: "missing"}
+	}
+	return nil
+}
+
+// AbortArgs is the schema of ABORT: the msg_id of the command to cancel
+type AbortArgs struct {
+	MsgID *string `json:"msg_id"`
+}
+
+func (a AbortArgs) Validate() error {
+	if a.MsgID == nil || *a.MsgID == "" {
+		return &ArgError{Field: "msg_id", Problem: "missing"}
+	}
+	return nil
+}
+
+// FaultArgs is the schema of INJECT_FAULT: a named fault, its parameter
+// (default per fault when missing) and how long it stays active
+type FaultArgs struct {
+	Fault     *string  `json:"fault"`
+	Value     *float64 `json:"value,omitempty"`
+	DurationS *float64 `json:"duration_s"`
+}
+
+func (a *FaultArgs) Validate() error {
+	if a.Fault == nil {
+		return &ArgError{Field: "fault", Problem: "missing"}
+	}
+	_, param, ok := fault.Lookup(*a.Fault)
+	if !ok {
+		return &ArgError{Field: "fault", Problem: fmt.Sprintf("unknown fault %q, one of %s", *a.Fault, strings.Join(fault.Names(), ", "))}
+	}
+	if a.Value == nil {
+		value := param.Default
+		a.Value = &value
+	}
+	if err := (AxisLimit{Min: param.Min, Max: param.Max}).check("value", a.Value); err != nil {
+		return err
+	}
+	if err := (AxisLimit{Min: 0, Max: 3600}).check("duration_s", a.DurationS); err != nil {
+		return err
+	}
+	if *a.DurationS == 0 {
+		return &ArgError{Field: "duration_s", Problem: "must be > 0"}
+	}
+	return nil
+}
+
+// ClearFaultArgs is the schema of CLEAR_FAULT, no fault clears them all
+type ClearFaultArgs struct {
+	Fault *string `json:"fault,omitempty"`
+}
+
+func (a ClearFaultArgs) Validate() error {
+	if a.Fault == nil {
+		return nil
+	}
+	if _, _, ok := fault.Lookup(*a.Fault); !ok {
+		return &ArgError{Field: "fault", Problem: fmt.Sprintf("unknown fault %q, one of %s", *a.Fault, strings.Join(fault.Names(), ", "))}
+	}
+	return nil
+}
+
+// DecodeArgs decodes the raw args of a command into the schema `into`
+// (a pointer). Unknown fields and wrong types are returned as *ArgError.
+func DecodeArgs(raw json.RawMessage, into Args) error {
+	if len(raw) == 0 || string(raw) == "null" {
+		raw = json.RawMessage("{}")
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(into); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return &ArgError{Field: typeErr.Field, Problem: fmt.Sprintf("expected %s", typeErr.Type)}
+		}
+		if name, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			return &ArgError{Field: strings.Trim(name, `"`), Problem: "unknown field"}
+		}
+		return &ArgError{Field: "args", Problem: err.Error()}
+	}
+	return nil
+}
+
+// Schema describes the fields of an argument schema as name -> type,
+// used for the HELP and CAPABILITIES output
+func Schema(args Args) map[string]string {
+	schema := map[string]string{}
+	t := reflect.TypeOf(args)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return schema
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" {
+			name = f.Name
+		}
+		if name == "-" {
+			continue
+		}
+		schema[name] = typeName(f.Type)
+	}
+	return schema
+}
+
+func typeName(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Float32, reflect.Float64, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "number"
+	case reflect.Bool:
+		return "bool"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		return "list"
+	default:
+		return "object"
+	}
+}
+
+// HistoryArgs is the schema of GET_HISTORY: the entries of one msg_id or
+// of a time range (RFC 3339, open ended when missing), at most limit
+type HistoryArgs struct {
+	MsgID *string  `json:"msg_id,omitempty"`
+	From  *string  `json:"from,omitempty"`
+	To    *string  `json:"to,omitempty"`
+	Limit *float64 `json:"limit,omitempty"`
+}
+
+// DefaultHistoryLimit when GET_HISTORY has no limit
+const DefaultHistoryLimit = 100
+
+func (a *HistoryArgs) Validate() error {
+	if a.MsgID != nil && (a.From != nil || a.To != nil) {
+		return &ArgError{Field: "msg_id", Problem: "either msg_id or from / to, not both"}
+	}
+	if a.MsgID != nil && *a.MsgID == "" {
+		return &ArgError{Field: "msg_id", Problem: "empty"}
+	}
+	from, to, err := a.Range()
+	if err != nil {
+		return err
+	}
+	if !from.IsZero() && !to.IsZero() && to.Before(from) {
+		return &ArgError{Field: "to", Problem: "before from"}
+	}
+	if a.Limit == nil {
+		limit := float64(DefaultHistoryLimit)
+		a.Limit = &limit
+	}
+	if err := (AxisLimit{Min: 1, Max: 1000}).check("limit", a.Limit); err != nil {
+		return err
+	}
+	if *a.Limit != math.Trunc(*a.Limit) {
+		return &ArgError{Field: "limit", Problem: "must be a whole number"}
+	}
+	return nil
+}
+
+// Range of the arguments, a zero time for a missing end
+func (a HistoryArgs) Range() (from, to time.Time, err error) {
+	parse := func(field string, v *string) (time.Time, error) {
+		if v == nil {
+			return time.Time{}, nil
+		}
+		t, err := time.Parse(time.RFC3339, *v)
+		if err != nil {
+			return time.Time{}, &ArgError{Field: field, Problem: fmt.Sprintf("%q is not an RFC 3339 time", *v)}
+		}
+		return t, nil
+	}
+	if from, err = parse("from", a.From); err != nil {
+		return
+	}
+	to, err = parse("to", a.To)
+	return
+}
@@ -0,0 +1,56 @@
+package command
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestArgsSchemas(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  func() Args
+		raw   string
+		field string // Field named by the *ArgError, "" for valid arguments
+	}{
+		{name: "maneuver ok", args: func() Args { return &ManeuverArgs{} }, raw: `{"x": 10, "y": 0, "z": -5}`},
+		{name: "maneuver out of range", args: func() Args { return &ManeuverArgs{} }, raw: `{"x": 10, "y": 256, "z": 0}`, field: "y"},
+		{name: "maneuver missing axis", args: func() Args { return &ManeuverArgs{} }, raw: `{"x": 10, "y": 0}`, field: "z"},
+		{name: "maneuver unknown field", args: func() Args { return &ManeuverArgs{} }, raw: `{"x": 1, "y": 0, "z": 0, "w": 1}`, field: "w"},
+		{name: "maneuver wrong type", args: func() Args { return &ManeuverArgs{} }, raw: `{"x": "fast", "y": 0, "z": 0}`, field: "x"},
+		{name: "maneuver zero vector", args: func() Args { return &ManeuverArgs{} }, raw: `{"x": 0, "y": 0, "z": 0}`, field: "x,y,z"},
+		{name: "maneuver no args", args: func() Args { return &ManeuverArgs{} }, raw: ``, field: "x"},
+		{name: "inhibit missing", args: func() Args { return &InhibitArgs{} }, raw: `{}`, field: "inhibit"},
+		{name: "inhibit unknown field", args: func() Args { return &InhibitArgs{} }, raw: `{"inhibit": true, "force": true}`, field: "force"},
+		{name: "abort missing", args: func() Args { return &AbortArgs{} }, raw: `{"msg_id": ""}`, field: "msg_id"},
+		{name: "fault unknown", args: func() Args { return &FaultArgs{} }, raw: `{"fault": "METEOR", "duration_s": 5}`, field: "fault"},
+		{name: "fault out of range", args: func() Args { return &FaultArgs{} }, raw: `{"fault": "BROWNOUT", "duration_s": 4000}`, field: "duration_s"},
+		{name: "fault missing duration", args: func() Args { return &FaultArgs{} }, raw: `{"fault": "BROWNOUT"}`, field: "duration_s"},
+		{name: "history both", args: func() Args { return &HistoryArgs{} }, raw: `{"msg_id": "m-1", "from": "2026-01-01T00:00:00Z"}`, field: "msg_id"},
+		{name: "history bad time", args: func() Args { return &HistoryArgs{} }, raw: `{"from": "yesterday"}`, field: "from"},
+		{name: "history limit out of range", args: func() Args { return &HistoryArgs{} }, raw: `{"limit": 5000}`, field: "limit"},
+		{name: "no args unknown field", args: func() Args { return &NoArgs{} }, raw: `{"x": 1}`, field: "x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.args()
+			err := DecodeArgs(json.RawMessage(tt.raw), args)
+			if err == nil {
+				err = args.Validate()
+			}
+			if tt.field == "" {
+				if err != nil {
+					t.Fatalf("valid arguments refused: %v", err)
+				}
+				return
+			}
+			var argErr *ArgError
+			if !errors.As(err, &argErr) {
+				t.Fatalf("error %v is not an *ArgError", err)
+			}
+			if argErr.Field != tt.field {
+				t.Fatalf("error names %q (%s), want %q", argErr.Field, argErr.Problem, tt.field)
+			}
+		})
+	}
+}
@@ -2,43 +2,71 @@ package command
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-)
-
-type CmdType string
+	"regexp"
 
-const (
-	INSPECT_PANEL CmdType = "INSPECT_PANEL"
-	THRUST        CmdType = "THRUST"
+	"github.com/google/uuid"
 )
 
 // Holds a passed command
 type Command struct {
-	CMD         string `json:"CMD"`
-	CMD_COUNTER int    `json:"CMD_COUNTER"`
-	CMD_HASH    string `json:"CMD_HASH"`
+	MSG_ID      string          `json:"msg_id"` // Echoed on every reply to this command
+	CMD         string          `json:"CMD"`
+	CMD_COUNTER int             `json:"CMD_COUNTER"`
+	CMD_HASH    string          `json:"CMD_HASH"`
+	ARGS        json.RawMessage `json:"args,omitempty"` // Decoded per command by ParseArgs
 }
 
-func ParseCommand(payload string) Command {
+// ParseCommand decodes a CMD_Q payload. On error the returned Command still
+// carries whatever msg_id (and CMD) could be recovered from the payload,
+// so the host can be told which of its messages was malformed.
+func ParseCommand(payload string) (Command, error) {
 	var e Command
 	// Parse the JSON payload into the Command struct
 	if err := json.Unmarshal([]byte(payload), &e); err != nil {
-		//panic(err)
-		fmt.Println("ParseCommand error: %w", err)
-		return Command{}
+		return recoverCommand(payload), fmt.Errorf("malformed payload: %w", err)
 	}
 	//if err := e.Command.Validate(); err != nil {
 	//	panic(err)
 	//}
-	return e
+	return e.Normalize()
 }
 
-// Validate ensures the Action is one of the allowed values
-func (a CmdType) Validate() error {
-	switch a {
-	case INSPECT_PANEL, THRUST:
-		return nil
-	default:
-		return fmt.Errorf("invalid action: %s", a)
+// Normalize fills in a missing msg_id and checks that CMD is set, for a
+// command decoded from any wire encoding
+func (e Command) Normalize() (Command, error) {
+	// Older hosts do not send a msg_id, fall back to the command hash
+	// so that replies can still be correlated
+	if e.MSG_ID == "" {
+		e.MSG_ID = e.CMD_HASH
+	}
+	if e.MSG_ID == "" {
+		e.MSG_ID = uuid.New().String()
 	}
+	if e.CMD == "" {
+		return e, errors.New("malformed payload: missing CMD")
+	}
+	return e, nil
+}
+
+var (
+	msgIDPattern = regexp.MustCompile(`"msg_id"\s*:\s*"([^"]*)"`)
+	hashPattern  = regexp.MustCompile(`"CMD_HASH"\s*:\s*"([^"]*)"`)
+	cmdPattern   = regexp.MustCompile(`"CMD"\s*:\s*"([^"]*)"`)
+)
+
+// recoverCommand picks msg_id and CMD out of a payload that is not valid JSON
+// (or has fields of the wrong type)
+func recoverCommand(payload string) Command {
+	var e Command
+	if m := msgIDPattern.FindStringSubmatch(payload); m != nil {
+		e.MSG_ID = m[1]
+	} else if m := hashPattern.FindStringSubmatch(payload); m != nil {
+		e.MSG_ID = m[1]
+	}
+	if m := cmdPattern.FindStringSubmatch(payload); m != nil {
+		e.CMD = m[1]
+	}
+	return e
 }
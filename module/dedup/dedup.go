@@ -0,0 +1,280 @@
+package dedup
+
+import (
+	"communication_module/logger"
+	"communication_module/protocol"
+	"communication_module/transport"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Remembers which msg_ids the module has already seen so that a retried
+// command is answered from the cache instead of being executed again.
+// Entries live in the transport (Redis) so deduplication survives a module
+// restart. An IN_FLIGHT entry holds a lease the running module renews (see
+// Hold), another module only takes the command over once it expired.
+
+const (
+	IN_FLIGHT = "IN_FLIGHT"
+	DONE      = "DONE"
+)
+
+// Entry stored per msg_id
+type Entry struct {
+	MsgID      string          `json:"msg_id"`
+	Cmd        string          `json:"cmd"`
+	State      string          `json:"state"` // IN_FLIGHT or DONE
+	ReceivedAt int64           `json:"received_at"`
+	Reply      *protocol.Reply `json:"reply,omitempty"`       // Final reply once DONE
+	Owner      string          `json:"owner,omitempty"`       // Store (module run) that took the command
+	LeaseUntil int64           `json:"lease_until,omitempty"` // Unix ms, an IN_FLIGHT entry past it was abandoned
+}
+
+// DefaultLease of IN_FLIGHT entries, renewed every third of it
+var DefaultLease = 15 * time.Second
+
+// Store is a dedup cache bounded by TTL and entry count
+type Store struct {
+	tr         transport.Transport
+	prefix     string
+	ttl        time.Duration
+	maxEntries int64
+	owner      string
+	lease      time.Duration
+}
+
+func NewStore(tr transport.Transport, ttl time.Duration, maxEntries int64) *Store {
+	if ttl <= 0 {
+		ttl = 10 * time.Minute
+	}
+	if maxEntries <= 0 {
+		maxEntries = 1000
+	}
+	return &Store{
+		tr:         tr,
+		prefix:     "CMD_DEDUP",
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		owner:      uuid.NewString(),
+		lease:      DefaultLease,
+	}
+}
+
+func (s *Store) key(msgID string) string {
+	return s.prefix + ":" + msgID
+}
+
+func (s *Store) index() string {
+	return s.prefix + "_INDEX"
+}
+
+// Begin marks msgID as in flight. If msgID was seen before the stored
+// entry is returned with dup set and the caller must not run the command.
+// A command whose lease expired (the module running it crashed or was
+// stopped) is taken over and runs again. The caller keeps the lease with
+// Hold while the command runs. The takeover is a compare-and-set on the
+// expired entry, so of several modules seeing it only one runs the command.
+func (s *Store) Begin(ctx context.Context, msgID, cmd string) (entry Entry, dup bool, err error) {
+	now := time.Now()
+	entry = Entry{MsgID: msgID, Cmd: cmd, State: IN_FLIGHT, ReceivedAt: now.Unix(), Owner: s.owner, LeaseUntil: s.leaseUntil(now)}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return entry, false, fmt.Errorf("dedup marshal: %w", err)
+	}
+
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		created, err := s.tr.SetNX(ctx, s.key(msgID), string(data), s.ttl)
+		if err != nil {
+			return entry, false, fmt.Errorf("dedup setnx: %w", err)
+		}
+		if created {
+			if err := s.track(ctx, msgID); err != nil {
+				return entry, false, err
+			}
+			return entry, false, nil
+		}
+
+		prior, raw, err := s.get(ctx, msgID)
+		if errors.Is(err, transport.ErrNil) {
+			// Expired or forgotten in between, try to create it again
+			continue
+		}
+		if err != nil {
+			return entry, false, err
+		}
+		if prior.State == DONE || prior.LeaseUntil > now.UnixMilli() {
+			return prior, true, nil
+		}
+		// Take the abandoned entry over, unless another module was faster
+		swapped, err := s.tr.CompareAndSwap(ctx, s.key(msgID), raw, string(data), s.ttl)
+		if err != nil {
+			return entry, false, fmt.Errorf("dedup takeover: %w", err)
+		}
+		if swapped {
+			return entry, false, nil
+		}
+	}
+	return entry, false, fmt.Errorf("dedup begin %s: still contended after %d attempts", msgID, maxAttempts)
+}
+
+// maxAttempts of Begin when the entry keeps changing under it
+const maxAttempts = 5
+
+func (s *Store) leaseUntil(now time.Time) int64 {
+	return now.Add(s.lease).UnixMilli()
+}
+
+// Hold renews the lease of msgID until the returned stop is called, which
+// must happen before Complete or Forget
+func (s *Store) Hold(ctx context.Context, msgID string) (stop func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(s.lease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if err := s.renew(ctx, msgID); err != nil && ctx.Err() == nil {
+				logger.Error("Could not renew the lease of ", msgID, ": ", err)
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// renew extends the lease of an IN_FLIGHT entry of this store
+func (s *Store) renew(ctx context.Context, msgID string) error {
+	entry, raw, err := s.get(ctx, msgID)
+	if err != nil {
+		return err
+	}
+	if entry.State != IN_FLIGHT || entry.Owner != s.owner {
+		return fmt.Errorf("dedup renew %s: taken over by %s", msgID, entry.Owner)
+	}
+	entry.LeaseUntil = s.leaseUntil(time.Now())
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("dedup marshal: %w", err)
+	}
+	// Only if nobody took it over since the read
+	swapped, err := s.tr.CompareAndSwap(ctx, s.key(msgID), raw, string(data), s.ttl)
+	if err != nil {
+		return fmt.Errorf("dedup renew: %w", err)
+	}
+	if !swapped {
+		return fmt.Errorf("dedup renew %s: changed while renewing", msgID)
+	}
+	return nil
+}
+
+// Complete stores the final reply of msgID for later duplicates
+func (s *Store) Complete(ctx context.Context, msgID string, reply protocol.Reply) error {
+	entry, err := s.Get(ctx, msgID)
+	if err != nil {
+		return err
+	}
+	entry.State = DONE
+	entry.LeaseUntil = 0
+	entry.Reply = &reply
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("dedup marshal: %w", err)
+	}
+	if err := s.tr.Set(ctx, s.key(msgID), string(data), s.ttl); err != nil {
+		return fmt.Errorf("dedup set: %w", err)
+	}
+	return nil
+}
+
+// Forget removes msgID, a retry of it runs again
+func (s *Store) Forget(ctx context.Context, msgID string) error {
+	if err := s.tr.Del(ctx, s.key(msgID)); err != nil {
+		return fmt.Errorf("dedup del: %w", err)
+	}
+	return nil
+}
+
+// Cacheable reports whether a final reply is kept for duplicates. Outcomes
+// a retry could change (busy, timed out, refused in the current state) are
+// not, the retry runs again. A command that was ACCEPTED and ran, or that
+// can never run (bad arguments, unknown), is answered from the cache.
+func Cacheable(reply protocol.Reply) bool {
+	switch {
+	case !reply.IsFinal():
+		return false
+	case reply.Reason == protocol.TIMEOUT:
+		return false
+	case reply.Status == protocol.REJECTED:
+		return reply.Reason == protocol.INVALID_ARGS
+	}
+	return true
+}
+
+// Get returns the stored entry for msgID
+func (s *Store) Get(ctx context.Context, msgID string) (Entry, error) {
+	entry, _, err := s.get(ctx, msgID)
+	return entry, err
+}
+
+// get returns the entry for msgID and its stored form, for CompareAndSwap
+func (s *Store) get(ctx context.Context, msgID string) (Entry, string, error) {
+	var entry Entry
+	data, err := s.tr.Get(ctx, s.key(msgID))
+	if err != nil {
+		return entry, "", fmt.Errorf("dedup get %s: %w", msgID, err)
+	}
+	if err := json.Unmarshal([]byte(data), &entry); err != nil {
+		return entry, "", fmt.Errorf("dedup unmarshal: %w", err)
+	}
+	return entry, data, nil
+}
+
+// track adds msgID to the index (newest first) and evicts the oldest
+// entries once the store grows past maxEntries. Ids that expired on their
+// own stay in the index until they are evicted, deleting them is a no-op.
+func (s *Store) track(ctx context.Context, msgID string) error {
+	count, err := s.tr.LPush(ctx, s.index(), msgID)
+	if err != nil {
+		return fmt.Errorf("dedup index: %w", err)
+	}
+
+	keys := []string{}
+	for ; count > s.maxEntries; count-- {
+		oldest, err := s.tr.RPop(ctx, s.index())
+		if errors.Is(err, transport.ErrNil) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("dedup evict: %w", err)
+		}
+		keys = append(keys, s.key(oldest))
+	}
+	return s.tr.Del(ctx, keys...)
+}
+
+// DuplicateReply builds the reply sent for a duplicate msg_id: the cached
+// final reply if the command finished, otherwise its in flight status.
+func DuplicateReply(entry Entry) protocol.Reply {
+	var reply protocol.Reply
+	if entry.State == DONE && entry.Reply != nil {
+		reply = *entry.Reply
+	} else {
+		reply = protocol.NewReply(entry.MsgID, entry.Cmd, protocol.ACCEPTED, "Command already in progress")
+	}
+	reply.Dup = true
+	return reply
+}
@@ -0,0 +1,195 @@
+package dedup
+
+import (
+	"communication_module/protocol"
+	"communication_module/transport"
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCacheable(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply protocol.Reply
+		want  bool
+	}{
+		{"result", protocol.NewReply("m", "PING", protocol.RESULT, "pong"), true},
+		{"error after running", protocol.Fail("m", "PERFORM_MANEUVER", protocol.INHIBIT_ABORT, "aborted"), true},
+		{"unknown command", protocol.Fail("m", "NOPE", protocol.UNRECOGNIZED_COMMAND, "unknown"), true},
+		{"invalid args", protocol.Reject("m", "PERFORM_MANEUVER", protocol.INVALID_ARGS, "x"), true},
+		{"busy", protocol.Reject("m", "INSPECT_PANEL", protocol.BUSY, "busy"), false},
+		{"not idle", protocol.Reject("m", "INSPECT_PANEL", protocol.MODULE_NOT_IDLE, "ACTIVE"), false},
+		{"timeout", protocol.Fail("m", "INSPECT_PANEL", protocol.TIMEOUT, "timed out"), false},
+		{"progress", protocol.NewReply("m", "PERFORM_MANEUVER", protocol.PROGRESS, "burning"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Cacheable(tt.reply); got != tt.want {
+				t.Fatalf("Cacheable(%s %s) = %v, want %v", tt.reply.Status, tt.reply.Reason, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLeaseTakeover(t *testing.T) {
+	tests := []struct {
+		name string
+		hold bool // The first module keeps renewing the lease
+		wait time.Duration
+		dup  bool // The second module sees a duplicate
+	}{
+		{name: "lease valid", wait: 0, dup: true},
+		{name: "lease expired", wait: 150 * time.Millisecond, dup: false},
+		{name: "lease renewed", hold: true, wait: 150 * time.Millisecond, dup: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			tr := transport.NewMemory(0)
+			first, second := NewStore(tr, time.Minute, 10), NewStore(tr, time.Minute, 10)
+			first.lease, second.lease = 60*time.Millisecond, 60*time.Millisecond
+
+			if _, dup, err := first.Begin(ctx, "m-1", "PING"); err != nil || dup {
+				t.Fatalf("first Begin: dup %v, err %v", dup, err)
+			}
+			if tt.hold {
+				defer first.Hold(ctx, "m-1")()
+			}
+			time.Sleep(tt.wait)
+
+			entry, dup, err := second.Begin(ctx, "m-1", "PING")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if dup != tt.dup {
+				t.Fatalf("second Begin dup %v, want %v", dup, tt.dup)
+			}
+			if want := map[bool]string{true: first.owner, false: second.owner}[tt.dup]; entry.Owner != want {
+				t.Fatalf("entry owned by %s, want %s", entry.Owner, want)
+			}
+		})
+	}
+}
+
+func TestConcurrentTakeover(t *testing.T) {
+	ctx := context.Background()
+	tr := transport.NewMemory(0)
+	first := NewStore(tr, time.Minute, 10)
+	first.lease = 10 * time.Millisecond
+	if _, dup, err := first.Begin(ctx, "m-1", "PING"); err != nil || dup {
+		t.Fatalf("first Begin: dup %v, err %v", dup, err)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	// Every module sees the expired lease, only one may take it over
+	const modules = 8
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	owners := []string{}
+	for i := 0; i < modules; i++ {
+		s := NewStore(tr, time.Minute, 10)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			entry, dup, err := s.Begin(ctx, "m-1", "PING")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if !dup {
+				mu.Lock()
+				owners = append(owners, entry.Owner)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(owners) != 1 {
+		t.Fatalf("%d modules took m-1 over, want 1", len(owners))
+	}
+	if entry, err := first.Get(ctx, "m-1"); err != nil || entry.Owner != owners[0] {
+		t.Fatalf("entry owned by %s (%v), want %s", entry.Owner, err, owners[0])
+	}
+}
+
+func TestBeginComplete(t *testing.T) {
+	result := protocol.NewReply("m-1", "PING", protocol.RESULT, "pong")
+
+	tests := []struct {
+		name     string
+		complete bool // The first run finished
+		forget   bool // The first run ended transient and was forgotten
+		dup      bool
+		state    string
+	}{
+		{name: "in flight", dup: true, state: IN_FLIGHT},
+		{name: "done", complete: true, dup: true, state: DONE},
+		{name: "forgotten", forget: true, dup: false, state: IN_FLIGHT},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := NewStore(transport.NewMemory(0), time.Minute, 10)
+			if _, dup, err := s.Begin(ctx, "m-1", "PING"); err != nil || dup {
+				t.Fatalf("first Begin: dup %v, err %v", dup, err)
+			}
+			if tt.complete {
+				if err := s.Complete(ctx, "m-1", result); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.forget {
+				if err := s.Forget(ctx, "m-1"); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			entry, dup, err := s.Begin(ctx, "m-1", "PING")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if dup != tt.dup || entry.State != tt.state {
+				t.Fatalf("second Begin: dup %v state %s, want %v %s", dup, entry.State, tt.dup, tt.state)
+			}
+			if !dup {
+				return
+			}
+			reply := DuplicateReply(entry)
+			if !reply.Dup {
+				t.Fatal("duplicate reply not marked dup")
+			}
+			if tt.complete && (reply.Status != protocol.RESULT || reply.Message != "pong") {
+				t.Fatalf("duplicate reply %s %q, want the cached RESULT", reply.Status, reply.Message)
+			}
+			if !tt.complete && reply.Status != protocol.ACCEPTED {
+				t.Fatalf("duplicate reply %s, want ACCEPTED", reply.Status)
+			}
+		})
+	}
+}
+
+func TestEviction(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore(transport.NewMemory(0), time.Minute, 3)
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		if _, _, err := s.Begin(ctx, id, "PING"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		msgID string
+		kept  bool
+	}{
+		{"a", false}, {"b", false}, {"c", true}, {"d", true}, {"e", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.msgID, func(t *testing.T) {
+			_, err := s.Get(ctx, tt.msgID)
+			if kept := err == nil; kept != tt.kept {
+				t.Fatalf("kept %v, want %v (%v)", kept, tt.kept, err)
+			}
+		})
+	}
+}
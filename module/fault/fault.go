@@ -0,0 +1,188 @@
+package fault
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Fault injection
+//
+// Named faults for reproducible QA scenarios. A fault is active for its
+// duration (or until CLEAR_FAULT) and its effect is applied by the part of
+// the module it targets: the environment tick (BROWNOUT, OVERTEMP), the
+// host heartbeat check (PAUSE_HEARTBEAT) and the reply publisher
+// (DROP_REPLIES, DELAY_REPLIES). Random decisions come from a seeded source.
+
+type Kind string
+
+const (
+	BROWNOUT        Kind = "BROWNOUT"        // Battery pinned at Value %
+	OVERTEMP        Kind = "OVERTEMP"        // Temperature ramps up by Value °C/s
+	PAUSE_HEARTBEAT Kind = "PAUSE_HEARTBEAT" // The next Value host beats are ignored
+	DROP_REPLIES    Kind = "DROP_REPLIES"    // Each reply is dropped with probability Value
+	DELAY_REPLIES   Kind = "DELAY_REPLIES"   // Each reply is published Value ms late
+)
+
+// Param describes the Value of a fault kind
+type Param struct {
+	Unit    string
+	Default float64
+	Min     float64
+	Max     float64
+}
+
+// Kinds are the known faults and their parameter
+var Kinds = map[Kind]Param{
+	BROWNOUT:        {Unit: "%", Default: 15, Min: 0, Max: 100},
+	OVERTEMP:        {Unit: "°C/s", Default: 1, Min: 0, Max: 20},
+	PAUSE_HEARTBEAT: {Unit: "beats", Default: 5, Min: 1, Max: 1000},
+	DROP_REPLIES:    {Unit: "p", Default: 0.5, Min: 0, Max: 1},
+	DELAY_REPLIES:   {Unit: "ms", Default: 1000, Min: 0, Max: 30000},
+}
+
+// Lookup returns the parameter of a fault kind
+func Lookup(name string) (Kind, Param, bool) {
+	p, ok := Kinds[Kind(name)]
+	return Kind(name), p, ok
+}
+
+// Names of the known faults, sorted
+func Names() []string {
+	names := make([]string, 0, len(Kinds))
+	for k := range Kinds {
+		names = append(names, string(k))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Fault is an active fault as reported in telemetry
+type Fault struct {
+	Kind    Kind      `json:"kind"`
+	Value   float64   `json:"value"`
+	Started time.Time `json:"started"`
+	Expires time.Time `json:"expires"`
+}
+
+func (f Fault) String() string {
+	return fmt.Sprintf("%s %g%s until %s", f.Kind, f.Value, Kinds[f.Kind].Unit, f.Expires.Format(time.TimeOnly))
+}
+
+// Injector holds the active faults, at most one per kind
+type Injector struct {
+	mu     sync.Mutex
+	active map[Kind]*Fault
+	rng    *rand.Rand
+	now    func() time.Time
+}
+
+func NewInjector(seed int64) *Injector {
+	return &Injector{
+		active: map[Kind]*Fault{},
+		rng:    rand.New(rand.NewSource(seed)),
+		now:    time.Now,
+	}
+}
+
+// Inject activates a fault for duration, replacing an active one of the same kind
+func (in *Injector) Inject(kind Kind, value float64, duration time.Duration) Fault {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	now := in.now()
+	f := &Fault{Kind: kind, Value: value, Started: now, Expires: now.Add(duration)}
+	in.active[kind] = f
+	return *f
+}
+
+// Clear deactivates a fault, it reports whether it was active
+func (in *Injector) Clear(kind Kind) bool {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.expire()
+	_, ok := in.active[kind]
+	delete(in.active, kind)
+	return ok
+}
+
+// ClearAll deactivates every fault and returns their kinds
+func (in *Injector) ClearAll() []Kind {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.expire()
+	kinds := []Kind{}
+	for k := range in.active {
+		kinds = append(kinds, k)
+	}
+	sort.Slice(kinds, func(i, j int) bool { return kinds[i] < kinds[j] })
+	in.active = map[Kind]*Fault{}
+	return kinds
+}
+
+// Active returns the active faults sorted by kind
+func (in *Injector) Active() []Fault {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.expire()
+	faults := []Fault{}
+	for _, f := range in.active {
+		faults = append(faults, *f)
+	}
+	sort.Slice(faults, func(i, j int) bool { return faults[i].Kind < faults[j].Kind })
+	return faults
+}
+
+// Get returns the fault of kind if it is active
+func (in *Injector) Get(kind Kind) (Fault, bool) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.expire()
+	f, ok := in.active[kind]
+	if !ok {
+		return Fault{}, false
+	}
+	return *f, true
+}
+
+// SkipBeat reports whether a host heartbeat has to be ignored. Every ignored
+// beat counts down PAUSE_HEARTBEAT, which clears once it reaches zero.
+func (in *Injector) SkipBeat() bool {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.expire()
+	f, ok := in.active[PAUSE_HEARTBEAT]
+	if !ok {
+		return false
+	}
+	f.Value--
+	if f.Value <= 0 {
+		delete(in.active, PAUSE_HEARTBEAT)
+	}
+	return true
+}
+
+// Reply decides the fate of a reply: dropped, or published after delay
+func (in *Injector) Reply() (drop bool, delay time.Duration) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.expire()
+	if f, ok := in.active[DROP_REPLIES]; ok && in.rng.Float64() < f.Value {
+		return true, 0
+	}
+	if f, ok := in.active[DELAY_REPLIES]; ok {
+		delay = time.Duration(f.Value * float64(time.Millisecond))
+	}
+	return false, delay
+}
+
+// expire removes the faults past their duration, called with mu held
+func (in *Injector) expire() {
+	now := in.now()
+	for k, f := range in.active {
+		if !now.Before(f.Expires) {
+			delete(in.active, k)
+		}
+	}
+}
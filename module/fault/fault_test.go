@@ -0,0 +1,117 @@
+package fault
+
+import (
+	"testing"
+	"time"
+)
+
+// clock is a settable time for Injector.now
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time { return c.t }
+
+func TestExpire(t *testing.T) {
+	c := &clock{t: time.Unix(1000, 0)}
+	in := NewInjector(1)
+	in.now = c.now
+	in.Inject(OVERTEMP, 2, 10*time.Second)
+	in.Inject(BROWNOUT, 15, time.Minute)
+
+	tests := []struct {
+		after  time.Duration
+		active []Kind
+	}{
+		{after: 0, active: []Kind{BROWNOUT, OVERTEMP}},
+		{after: 10*time.Second - time.Millisecond, active: []Kind{BROWNOUT, OVERTEMP}},
+		{after: 10 * time.Second, active: []Kind{BROWNOUT}},
+		{after: time.Minute, active: []Kind{}},
+	}
+	for _, tt := range tests {
+		c.t = time.Unix(1000, 0).Add(tt.after)
+		var got []Kind
+		for _, f := range in.Active() {
+			got = append(got, f.Kind)
+		}
+		if len(got) != len(tt.active) {
+			t.Fatalf("after %s active %v, want %v", tt.after, got, tt.active)
+		}
+		for i := range got {
+			if got[i] != tt.active[i] {
+				t.Fatalf("after %s active %v, want %v", tt.after, got, tt.active)
+			}
+		}
+	}
+	if in.Clear(OVERTEMP) {
+		t.Fatal("Clear reported an expired fault as active")
+	}
+}
+
+func TestSkipBeat(t *testing.T) {
+	for _, beats := range []int{1, 3, 5} {
+		in := NewInjector(1)
+		in.Inject(PAUSE_HEARTBEAT, float64(beats), time.Minute)
+		skipped := 0
+		for i := 0; i < beats+3; i++ {
+			if in.SkipBeat() {
+				skipped++
+			}
+		}
+		if skipped != beats {
+			t.Fatalf("PAUSE_HEARTBEAT %d skipped %d beats", beats, skipped)
+		}
+		if _, ok := in.Get(PAUSE_HEARTBEAT); ok {
+			t.Fatalf("PAUSE_HEARTBEAT %d still active after its beats", beats)
+		}
+	}
+	if NewInjector(1).SkipBeat() {
+		t.Fatal("beat skipped without PAUSE_HEARTBEAT")
+	}
+}
+
+// replies returns the fate of n replies with DROP_REPLIES and DELAY_REPLIES
+// active: 'D' dropped, '+' delayed by 250ms
+func replies(t *testing.T, seed int64, n int) string {
+	t.Helper()
+	in := NewInjector(seed)
+	in.Inject(DROP_REPLIES, 0.5, time.Minute)
+	in.Inject(DELAY_REPLIES, 250, time.Minute)
+	fates := make([]byte, n)
+	for i := range fates {
+		drop, delay := in.Reply()
+		switch {
+		case drop:
+			fates[i] = 'D'
+		case delay == 250*time.Millisecond:
+			fates[i] = '+'
+		default:
+			t.Fatalf("reply %d neither dropped nor delayed 250ms: %s", i, delay)
+		}
+	}
+	return string(fates)
+}
+
+func TestReplySeeded(t *testing.T) {
+	first, again := replies(t, 42, 200), replies(t, 42, 200)
+	if first != again {
+		t.Fatalf("same seed, different fates:\n%s\n%s", first, again)
+	}
+	if other := replies(t, 43, 200); other == first {
+		t.Fatal("different seeds, same fates")
+	}
+	dropped := 0
+	for _, f := range first {
+		if f == 'D' {
+			dropped++
+		}
+	}
+	// p = 0.5 over 200 replies
+	if dropped < 70 || dropped > 130 {
+		t.Fatalf("%d of 200 replies dropped at p 0.5", dropped)
+	}
+}
+
+func TestReplyWithoutFaults(t *testing.T) {
+	if drop, delay := NewInjector(1).Reply(); drop || delay != 0 {
+		t.Fatalf("Reply without faults: drop %v delay %s", drop, delay)
+	}
+}
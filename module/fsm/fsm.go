@@ -0,0 +1,160 @@
+package fsm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Module state machine: IDLE -> ACTIVE -> SAFE (latched) -> IDLE
+//
+// The current state can only be changed through Transition, which looks the
+// edge up in the transition table and runs its guard first.
+
+type State string
+
+const (
+	IDLE   State = "IDLE"
+	ACTIVE State = "ACTIVE"
+	SAFE   State = "SAFE"
+)
+
+var ErrIllegalTransition = errors.New("illegal transition")
+
+// Event is emitted after every transition
+type Event struct {
+	From  State     `json:"from"`
+	To    State     `json:"to"`
+	Cause string    `json:"cause"`
+	MsgID string    `json:"msg_id,omitempty"` // Command that caused it, "" when the module did
+	Time  time.Time `json:"time"`
+}
+
+// Guard decides whether a transition may happen, a non nil error vetoes it
+type Guard func(ev Event) error
+
+// Always allows the transition
+func Always(Event) error { return nil }
+
+type Edge struct {
+	From State
+	To   State
+}
+
+// Transitions is the default transition table. Entering SAFE while SAFE
+// is allowed (and a no-op) so any fault can latch it without checking first.
+func Transitions() map[Edge]Guard {
+	return map[Edge]Guard{
+		{IDLE, ACTIVE}: Always,
+		{ACTIVE, IDLE}: Always,
+		{IDLE, SAFE}:   Always,
+		{ACTIVE, SAFE}: Always,
+		{SAFE, SAFE}:   Always,
+		{SAFE, IDLE}:   Always,
+	}
+}
+
+type Machine struct {
+	mu           sync.Mutex
+	current      State
+	table        map[Edge]Guard
+	onTransition func(Event)
+}
+
+func NewMachine(initial State) *Machine {
+	return &Machine{
+		current: initial,
+		table:   Transitions(),
+	}
+}
+
+// SetGuard replaces the guard of an existing edge of the table
+func (m *Machine) SetGuard(from, to State, g Guard) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	edge := Edge{from, to}
+	if _, ok := m.table[edge]; !ok {
+		return fmt.Errorf("%w: %s -> %s", ErrIllegalTransition, from, to)
+	}
+	if g == nil {
+		g = Always
+	}
+	m.table[edge] = g
+	return nil
+}
+
+// OnTransition registers the function called with every transition event
+func (m *Machine) OnTransition(f func(Event)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onTransition = f
+}
+
+func (m *Machine) Current() State {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.current
+}
+
+// Is reports whether the machine is currently in s
+func (m *Machine) Is(s State) bool {
+	return m.Current() == s
+}
+
+// Transition moves the machine to `to`. It fails if the edge is not in the
+// table or its guard vetoes it.
+func (m *Machine) Transition(to State, cause string) error {
+	return m.TransitionFor("", to, cause)
+}
+
+// TransitionFor is Transition caused by the command msgID
+func (m *Machine) TransitionFor(msgID string, to State, cause string) error {
+	return m.TransitionWith(msgID, to, cause, nil)
+}
+
+// TransitionWith is TransitionFor that calls commit once the transition
+// passed its guard, with the machine still locked. commit also runs for a
+// transition to the current state, it must not call back into the machine.
+func (m *Machine) TransitionWith(msgID string, to State, cause string, commit func(Event)) error {
+	m.mu.Lock()
+	ev := Event{From: m.current, To: to, Cause: cause, MsgID: msgID, Time: time.Now()}
+	guard, ok := m.table[Edge{ev.From, ev.To}]
+	if !ok {
+		m.mu.Unlock()
+		return fmt.Errorf("%w: %s -> %s (%s)", ErrIllegalTransition, ev.From, ev.To, cause)
+	}
+	if err := guard(ev); err != nil {
+		m.mu.Unlock()
+		return fmt.Errorf("%s -> %s refused: %w", ev.From, ev.To, err)
+	}
+	m.current = to
+	if commit != nil {
+		commit(ev)
+	}
+	if ev.From == ev.To {
+		m.mu.Unlock()
+		return nil
+	}
+	notify := m.onTransition
+	m.mu.Unlock()
+
+	if notify != nil {
+		notify(ev)
+	}
+	return nil
+}
+
+// View calls f with the current state and the machine locked, the state
+// can't change until f returns. f must not call back into the machine.
+func (m *Machine) View(f func(current State)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f(m.current)
+}
+
+// MarshalJSON encodes the machine as its current state
+func (m *Machine) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.Current())
+}
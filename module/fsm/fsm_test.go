@@ -0,0 +1,135 @@
+package fsm
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTransitionTable(t *testing.T) {
+	tests := []struct {
+		from, to State
+		legal    bool
+		event    bool // An event is emitted (not for a self transition)
+	}{
+		{IDLE, IDLE, false, false},
+		{IDLE, ACTIVE, true, true},
+		{IDLE, SAFE, true, true},
+		{ACTIVE, IDLE, true, true},
+		{ACTIVE, ACTIVE, false, false},
+		{ACTIVE, SAFE, true, true},
+		{SAFE, IDLE, true, true},
+		{SAFE, ACTIVE, false, false},
+		{SAFE, SAFE, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.from)+"->"+string(tt.to), func(t *testing.T) {
+			m := NewMachine(tt.from)
+			var events []Event
+			m.OnTransition(func(ev Event) { events = append(events, ev) })
+
+			err := m.TransitionFor("m-1", tt.to, "test")
+			if tt.legal && err != nil {
+				t.Fatalf("legal transition refused: %v", err)
+			}
+			if !tt.legal && !errors.Is(err, ErrIllegalTransition) {
+				t.Fatalf("illegal transition: err %v, want ErrIllegalTransition", err)
+			}
+			want := tt.from
+			if tt.legal {
+				want = tt.to
+			}
+			if current := m.Current(); current != want {
+				t.Fatalf("machine %s, want %s", current, want)
+			}
+
+			if !tt.event {
+				if len(events) != 0 {
+					t.Fatalf("events %+v, want none", events)
+				}
+				return
+			}
+			if len(events) != 1 {
+				t.Fatalf("events %+v, want one", events)
+			}
+			if ev := events[0]; ev.From != tt.from || ev.To != tt.to || ev.Cause != "test" || ev.MsgID != "m-1" || ev.Time.IsZero() {
+				t.Fatalf("event %+v", ev)
+			}
+		})
+	}
+}
+
+func TestGuardVeto(t *testing.T) {
+	veto := errors.New("not now")
+	tests := []struct {
+		name  string
+		cause string
+		moved bool
+	}{
+		{name: "vetoed", cause: "nope", moved: false},
+		{name: "allowed", cause: "ok", moved: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMachine(SAFE)
+			if err := m.SetGuard(SAFE, IDLE, func(ev Event) error {
+				if ev.Cause != "ok" {
+					return veto
+				}
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+			notified := false
+			m.OnTransition(func(Event) { notified = true })
+
+			err := m.Transition(IDLE, tt.cause)
+			if moved := err == nil; moved != tt.moved {
+				t.Fatalf("moved %v, want %v (%v)", moved, tt.moved, err)
+			}
+			if !tt.moved && !errors.Is(err, veto) {
+				t.Fatalf("err %v does not wrap the guard error", err)
+			}
+			if notified != tt.moved {
+				t.Fatalf("notified %v, want %v", notified, tt.moved)
+			}
+		})
+	}
+}
+
+func TestSetGuardUnknownEdge(t *testing.T) {
+	m := NewMachine(IDLE)
+	if err := m.SetGuard(SAFE, ACTIVE, Always); !errors.Is(err, ErrIllegalTransition) {
+		t.Fatalf("SetGuard on an illegal edge: %v", err)
+	}
+}
+
+func TestTransitionWithCommit(t *testing.T) {
+	tests := []struct {
+		name      string
+		from, to  State
+		committed bool
+	}{
+		{name: "transition", from: IDLE, to: SAFE, committed: true},
+		{name: "same state", from: SAFE, to: SAFE, committed: true},
+		{name: "illegal", from: SAFE, to: ACTIVE, committed: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMachine(tt.from)
+			var committed []Event
+			m.TransitionWith("m-1", tt.to, "test", func(ev Event) {
+				// Runs locked, the state is already the new one
+				if m.current != tt.to {
+					t.Errorf("commit in %s, want %s", m.current, tt.to)
+				}
+				committed = append(committed, ev)
+			})
+			if got := len(committed) == 1; got != tt.committed {
+				t.Fatalf("committed %+v, want %v", committed, tt.committed)
+			}
+			if tt.committed && (committed[0].From != tt.from || committed[0].MsgID != "m-1") {
+				t.Fatalf("commit event %+v", committed[0])
+			}
+		})
+	}
+}
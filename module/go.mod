@@ -2,11 +2,15 @@ module communication_module
 
 go 1.25.0
 
+require (
+	github.com/google/uuid v1.6.0
+	github.com/redis/go-redis/v9 v9.12.1
+	google.golang.org/protobuf v1.36.12
+)
+
 require (
 	github.com/cespare/xxhash/v2 v2.3.0 // indirect
 	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
-	github.com/google/uuid v1.6.0
-	github.com/redis/go-redis/v9 v9.12.1 // indirect
 	golang.org/x/sys v0.35.0 // indirect
 	golang.org/x/term v0.34.0 // indirect
 )
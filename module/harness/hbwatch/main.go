@@ -0,0 +1,121 @@
+// hbwatch is the host side of the module heartbeat, as a standalone harness.
+//
+//	go run ./harness/hbwatch            watch a running module, log LOST / RESTORED
+//	go run ./harness/hbwatch -selftest  publish, stop and restart a heartbeat and
+//	                                    check that it is declared lost and restored
+//
+// Interval and TTL come from MODULE_HEARTBEAT_INTERVAL / MODULE_HEARTBEAT_TTL
+// like in the module.
+package main
+
+import (
+	"communication_module/heartbeat"
+	"communication_module/transport"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func main() {
+	addr := flag.String("redis", "localhost:6379", "Redis address")
+	missed := flag.Int("missed", heartbeat.DefaultConfig.MissedLimit, "missed beats before the module is lost")
+	selftest := flag.Bool("selftest", false, "run the loss / restore scenario against a local publisher")
+	flag.Parse()
+
+	cfg, err := heartbeat.PublisherConfigFromEnv(heartbeat.DefaultPublisher)
+	if err != nil {
+		log.Fatal(err)
+	}
+	tr := transport.NewRedis(redis.NewClient(&redis.Options{Addr: *addr}), 0)
+	defer tr.Close()
+	ctx := context.Background()
+
+	if *selftest {
+		// Own key and channel, a running module is not disturbed
+		cfg.Key, cfg.Channel = cfg.Key+":selftest", cfg.Channel+":selftest"
+		if err := selfTest(ctx, tr, cfg, *missed); err != nil {
+			fmt.Println("FAIL:", err)
+			os.Exit(1)
+		}
+		fmt.Println("PASS")
+		return
+	}
+	watch(ctx, tr, cfg, *missed)
+}
+
+// watch logs every change of the module link
+func watch(ctx context.Context, tr transport.Transport, cfg heartbeat.PublisherConfig, missed int) {
+	w := heartbeat.NewWatcher(tr, cfg, missed)
+	ticker := time.NewTicker(cfg.Interval / 2)
+	defer ticker.Stop()
+	log.Printf("watching %s, interval %s, lost after %d missed beats", cfg.Key, cfg.Interval, missed)
+	for now := range ticker.C {
+		status, err := w.Poll(ctx, now)
+		if err != nil {
+			log.Print(err)
+			continue
+		}
+		if status.Changed {
+			if status.Lost {
+				log.Printf("MODULE LOST: %d beats missed, last seq %d", status.Missed, status.LastSeq)
+			} else {
+				log.Printf("MODULE RESTORED: seq %d, status %s, uptime %.1fs", status.LastSeq, w.Last.Status, w.Last.UptimeS)
+			}
+		}
+	}
+}
+
+// selfTest runs a publisher, stops it and restarts it, and checks the
+// watcher reports lost and restored within the expected time
+func selfTest(ctx context.Context, tr transport.Transport, cfg heartbeat.PublisherConfig, missed int) error {
+	w := heartbeat.NewWatcher(tr, cfg, missed)
+	publish := func() context.CancelFunc {
+		pctx, cancel := context.WithCancel(ctx)
+		go heartbeat.Publish(pctx, tr, cfg, func() string { return "SELFTEST" }, func(err error) { log.Print(err) })
+		return cancel
+	}
+	// Waits for the link to reach lost, polling like watch does
+	await := func(lost bool, within time.Duration) (time.Duration, error) {
+		start := time.Now()
+		for time.Since(start) < within {
+			status, err := w.Poll(ctx, time.Now())
+			if err != nil {
+				return 0, err
+			}
+			if status.Lost == lost && status.LastSeq > 0 {
+				return time.Since(start), nil
+			}
+			time.Sleep(cfg.Interval / 2)
+		}
+		return 0, fmt.Errorf("link lost=%v not reached within %s", lost, within)
+	}
+
+	stop := publish()
+	took, err := await(false, 3*cfg.Interval)
+	if err != nil {
+		return fmt.Errorf("first beat: %w", err)
+	}
+	fmt.Printf("alive after %s (seq %d)\n", took.Round(time.Millisecond), w.Last.Seq)
+
+	stop()
+	// Lost after `missed` beats past the one that was due, plus one poll
+	limit := time.Duration(missed+2)*cfg.Interval + cfg.Interval/2
+	if took, err = await(true, limit); err != nil {
+		return fmt.Errorf("after stopping: %w", err)
+	}
+	fmt.Printf("lost after %s\n", took.Round(time.Millisecond))
+
+	// A restarted module starts at seq 1 again
+	stop = publish()
+	defer stop()
+	if took, err = await(false, 3*cfg.Interval); err != nil {
+		return fmt.Errorf("after restarting: %w", err)
+	}
+	fmt.Printf("restored after %s (seq %d)\n", took.Round(time.Millisecond), w.Last.Seq)
+	return nil
+}
@@ -0,0 +1,148 @@
+package heartbeat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"communication_module/transport"
+)
+
+// Module heartbeat
+//
+// The other half of the liveness scheme: the module SETs its latest beat on
+// a key with a TTL (gone once the module stops) and PUBLISHes it on a
+// channel. The host watches either with a Watcher, which reuses the
+// Monitor, so both sides declare loss the same way.
+
+// ModuleBeat is a host Beat plus the module status and uptime
+type ModuleBeat struct {
+	Beat
+	Status  string  `json:"status"`
+	UptimeS float64 `json:"uptime_s"`
+}
+
+// PublisherConfig of the module heartbeat
+type PublisherConfig struct {
+	Interval time.Duration // Time between beats
+	TTL      time.Duration // Lifetime of the latest beat key
+	Key      string        // Latest beat, SET with TTL
+	Channel  string        // Every beat is PUBLISHed here
+}
+
+// DefaultPublisher beats every 500 ms like the host, the key outlives 3 beats
+var DefaultPublisher = PublisherConfig{
+	Interval: 500 * time.Millisecond,
+	TTL:      1500 * time.Millisecond,
+	Key:      "heartbeat:latest",
+	Channel:  "heartbeat",
+}
+
+// PublisherConfigFromEnv overrides the interval and TTL of cfg with
+// MODULE_HEARTBEAT_INTERVAL and MODULE_HEARTBEAT_TTL (Go durations, e.g. "250ms")
+func PublisherConfigFromEnv(cfg PublisherConfig) (PublisherConfig, error) {
+	for name, field := range map[string]*time.Duration{
+		"MODULE_HEARTBEAT_INTERVAL": &cfg.Interval,
+		"MODULE_HEARTBEAT_TTL":      &cfg.TTL,
+	} {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return cfg, fmt.Errorf("%s: invalid duration %q", name, value)
+		}
+		*field = d
+	}
+	if cfg.TTL < cfg.Interval {
+		return cfg, fmt.Errorf("heartbeat TTL %s shorter than interval %s", cfg.TTL, cfg.Interval)
+	}
+	return cfg, nil
+}
+
+// Publish beats every cfg.Interval until ctx is done. status is called for
+// every beat. Errors are passed to onErr (if set) and the next beat is tried.
+func Publish(ctx context.Context, tr transport.Transport, cfg PublisherConfig, status func() string, onErr func(error)) {
+	started := time.Now()
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+
+	var seq uint64
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case t := <-ticker.C:
+			seq++
+			beat := ModuleBeat{
+				Beat:    Beat{Seq: seq, TS: t.UTC()},
+				Status:  status(),
+				UptimeS: t.Sub(started).Seconds(),
+			}
+			data, _ := json.Marshal(beat)
+			payload := string(data)
+
+			if err := tr.Set(ctx, cfg.Key, payload, cfg.TTL); err != nil && onErr != nil {
+				onErr(fmt.Errorf("heartbeat SET: %w", err))
+			}
+			if _, err := tr.Publish(ctx, cfg.Channel, payload); err != nil && onErr != nil {
+				onErr(fmt.Errorf("heartbeat PUBLISH: %w", err))
+			}
+		}
+	}
+}
+
+// ParseModuleBeat decodes a module beat
+func ParseModuleBeat(payload string) (ModuleBeat, error) {
+	var b ModuleBeat
+	if err := json.Unmarshal([]byte(payload), &b); err != nil {
+		return ModuleBeat{}, fmt.Errorf("%w: %v", ErrBadBeat, err)
+	}
+	if b.Seq == 0 || b.TS.IsZero() {
+		return ModuleBeat{}, fmt.Errorf("%w: missing seq or ts", ErrBadBeat)
+	}
+	return b, nil
+}
+
+// Watcher is the host side: it polls the latest beat key of the module and
+// feeds it to a Monitor. An expired key is a missing beat like any other.
+type Watcher struct {
+	tr      transport.Transport
+	key     string
+	monitor *Monitor
+	Last    ModuleBeat // Last beat read
+}
+
+// NewWatcher watches cfg.Key, expecting a beat every cfg.Interval and
+// declaring the module lost after missedLimit missed beats
+func NewWatcher(tr transport.Transport, cfg PublisherConfig, missedLimit int) *Watcher {
+	return &Watcher{
+		tr:      tr,
+		key:     cfg.Key,
+		monitor: NewMonitor(Config{Interval: cfg.Interval, MissedLimit: missedLimit}, time.Now()),
+	}
+}
+
+// Poll reads the latest beat and returns the status of the module link
+func (w *Watcher) Poll(ctx context.Context, now time.Time) (Status, error) {
+	payload, err := w.tr.Get(ctx, w.key)
+	switch {
+	case errors.Is(err, transport.ErrNil):
+		// Key expired, no beat within the TTL
+	case err != nil:
+		return Status{}, err
+	default:
+		beat, err := ParseModuleBeat(payload)
+		if err != nil {
+			return Status{}, err
+		}
+		if w.monitor.Observe(beat.Beat, now) {
+			w.Last = beat
+		}
+	}
+	return w.monitor.Check(now), nil
+}
@@ -0,0 +1,65 @@
+package heartbeat
+
+import (
+	"communication_module/transport"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWatcher(t *testing.T) {
+	cfg := PublisherConfig{Interval: 20 * time.Millisecond, TTL: 60 * time.Millisecond, Key: "heartbeat:latest", Channel: "heartbeat"}
+	const missedLimit = 3
+
+	tests := []struct {
+		name    string
+		restart bool // A new publisher (seq from 1) takes over after the first stops
+		lost    bool
+	}{
+		{name: "publisher stopped", lost: true},
+		{name: "publisher restarted", restart: true, lost: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			tr := transport.NewMemory(10)
+			w := NewWatcher(tr, cfg, missedLimit)
+
+			publish := func() context.CancelFunc {
+				ctx, cancel := context.WithCancel(ctx)
+				go Publish(ctx, tr, cfg, func() string { return "IDLE" }, func(err error) { t.Error(err) })
+				return cancel
+			}
+			poll := func(d time.Duration) Status {
+				var s Status
+				for end := time.Now().Add(d); time.Now().Before(end); time.Sleep(5 * time.Millisecond) {
+					var err error
+					if s, err = w.Poll(ctx, time.Now()); err != nil {
+						t.Fatal(err)
+					}
+					if s.Lost {
+						return s
+					}
+				}
+				return s
+			}
+
+			stop := publish()
+			if s := poll(200 * time.Millisecond); s.Lost {
+				t.Fatalf("lost while publishing: %+v", s)
+			}
+			if w.Last.Seq == 0 || w.Last.Status != "IDLE" {
+				t.Fatalf("last beat %+v", w.Last)
+			}
+			stop()
+			if tt.restart {
+				defer publish()()
+			}
+
+			s := poll(time.Duration(missedLimit+2)*cfg.Interval + cfg.TTL)
+			if s.Lost != tt.lost {
+				t.Fatalf("lost %v, want %v: %+v", s.Lost, tt.lost, s)
+			}
+		})
+	}
+}
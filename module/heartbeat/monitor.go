@@ -0,0 +1,175 @@
+package heartbeat
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Host heartbeat monitor
+//
+// The host pushes sequenced beats {"seq": n, "ts": "<RFC3339>"}. A beat is
+// new when its timestamp is later than the last one accepted, so repeated
+// list entries and the beats from before a host restart (seq starts over,
+// the old entries stay in the list) don't count. The seq numbering only
+// counts beats that went missing. Liveness is judged on the module clock
+// (time since the last new beat), jitter on the host timestamps.
+
+// Config of the monitor
+type Config struct {
+	Interval    time.Duration // Expected time between host beats
+	MissedLimit int           // The host link is lost once this many beats are missed
+}
+
+// DefaultConfig: the host beats every 500 ms, 3 missed beats is a loss (spec)
+var DefaultConfig = Config{
+	Interval:    500 * time.Millisecond,
+	MissedLimit: 3,
+}
+
+// Beat is one host heartbeat
+type Beat struct {
+	Seq uint64    `json:"seq"`
+	TS  time.Time `json:"ts"`
+}
+
+var ErrBadBeat = errors.New("bad heartbeat")
+
+// Parse decodes a beat. A bare timestamp (the old format) is accepted as a
+// beat without sequence number.
+func Parse(payload string) (Beat, error) {
+	payload = strings.TrimSpace(payload)
+	var b Beat
+	if strings.HasPrefix(payload, "{") {
+		if err := json.Unmarshal([]byte(payload), &b); err != nil {
+			return Beat{}, fmt.Errorf("%w: %v", ErrBadBeat, err)
+		}
+		if b.TS.IsZero() {
+			return Beat{}, fmt.Errorf("%w: missing ts", ErrBadBeat)
+		}
+		return b, nil
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999"} {
+		if ts, err := time.Parse(layout, payload); err == nil {
+			return Beat{TS: ts}, nil
+		}
+	}
+	return Beat{}, fmt.Errorf("%w: %q", ErrBadBeat, payload)
+}
+
+// Status of the host link
+type Status struct {
+	LastSeq     uint64    `json:"last_seq"`
+	LastBeat    time.Time `json:"last_beat"`    // Host timestamp of the last beat
+	Missed      int       `json:"missed"`       // Beats overdue right now
+	MissedTotal uint64    `json:"missed_total"` // Beats missing from the seq numbering since start
+	JitterMs    float64   `json:"jitter_ms"`    // Smoothed deviation of the beat interval
+	Lost        bool      `json:"lost"`
+	Changed     bool      `json:"-"` // Lost changed with this check
+}
+
+// Monitor tracks the host heartbeat
+type Monitor struct {
+	mu        sync.Mutex
+	cfg       Config
+	last      Beat
+	seen      bool
+	arrival   time.Time // Module time of the last new beat (or the start)
+	gapMissed uint64    // Beats skipped in the seq numbering
+	jitter    float64   // Seconds
+	lost      bool
+}
+
+func NewMonitor(cfg Config, now time.Time) *Monitor {
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultConfig.Interval
+	}
+	if cfg.MissedLimit <= 0 {
+		cfg.MissedLimit = DefaultConfig.MissedLimit
+	}
+	return &Monitor{cfg: cfg, arrival: now}
+}
+
+func (m *Monitor) Config() Config {
+	return m.cfg
+}
+
+// IsNew reports whether b is newer than the last beat observed
+func (m *Monitor) IsNew(b Beat) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.isNew(b)
+}
+
+func (m *Monitor) isNew(b Beat) bool {
+	// A lower seq with a later timestamp is a restarted sender, a higher
+	// seq with an older timestamp a beat from before the restart
+	return !m.seen || b.TS.After(m.last.TS)
+}
+
+// Observe records a beat received at now, old or repeated beats are ignored.
+// It reports whether the beat was new.
+func (m *Monitor) Observe(b Beat, now time.Time) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.isNew(b) {
+		return false
+	}
+	if m.seen {
+		beats := uint64(1)
+		if b.Seq > m.last.Seq+1 && m.last.Seq != 0 {
+			beats = b.Seq - m.last.Seq
+			m.gapMissed += beats - 1
+		}
+		// Smoothed like RFC 3550 interarrival jitter, per beat across gaps
+		interval := b.TS.Sub(m.last.TS).Seconds() / float64(beats)
+		deviation := math.Abs(interval - m.cfg.Interval.Seconds())
+		m.jitter += (deviation - m.jitter) / 16
+	}
+	m.last = b
+	m.seen = true
+	m.arrival = now
+	return true
+}
+
+// Skip records a beat as seen without counting it as alive, for injected
+// heartbeat pauses. It reports whether the beat was new.
+func (m *Monitor) Skip(b Beat) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.isNew(b) {
+		return false
+	}
+	if m.seen && b.Seq > m.last.Seq && m.last.Seq != 0 {
+		m.gapMissed += b.Seq - m.last.Seq
+	}
+	m.last = b
+	m.seen = true
+	return true
+}
+
+// Check evaluates the link at now
+func (m *Monitor) Check(now time.Time) Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// The next beat is due one interval after the last, every full
+	// interval past that is a missed beat
+	missed := max(int(now.Sub(m.arrival)/m.cfg.Interval)-1, 0)
+	lost := missed >= m.cfg.MissedLimit
+	s := Status{
+		LastSeq:     m.last.Seq,
+		LastBeat:    m.last.TS,
+		Missed:      missed,
+		MissedTotal: m.gapMissed,
+		JitterMs:    m.jitter * 1000,
+		Lost:        lost,
+		Changed:     lost != m.lost,
+	}
+	m.lost = lost
+	return s
+}
@@ -0,0 +1,106 @@
+package heartbeat
+
+import (
+	"testing"
+	"time"
+)
+
+// hostList is the HOST_HEARTBEAT list: the host LPUSHes, the module reads
+// the newest window and observes it oldest first
+type hostList []Beat
+
+func (l *hostList) push(b Beat) {
+	*l = append(hostList{b}, *l...)
+}
+
+func (l hostList) observe(m *Monitor, now time.Time) {
+	window := l[:min(len(l), 10)]
+	for i := len(window) - 1; i >= 0; i-- {
+		m.Observe(window[i], now)
+	}
+}
+
+func TestMonitorLoss(t *testing.T) {
+	cfg := Config{Interval: 500 * time.Millisecond, MissedLimit: 3}
+	t0 := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		beats []uint64 // Seq of the beats sent, one per interval
+	}{
+		{name: "steady then death", beats: seqs(1, 20)},
+		{name: "restart then death", beats: append(seqs(1, 20), seqs(1, 3)...)},
+		{name: "restart with old seq in window then death", beats: append(seqs(1, 8), seqs(1, 1)...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMonitor(cfg, t0)
+			var list hostList
+			now := t0
+			for _, seq := range tt.beats {
+				now = now.Add(cfg.Interval)
+				list.push(Beat{Seq: seq, TS: now})
+				list.observe(m, now)
+				if s := m.Check(now); s.Lost {
+					t.Fatalf("lost at seq %d while beating: %+v", seq, s)
+				}
+			}
+			last := tt.beats[len(tt.beats)-1]
+			if s := m.Check(now); s.LastSeq != last {
+				t.Fatalf("last seq %d, want %d", s.LastSeq, last)
+			}
+
+			// The host dies, the old entries stay in the list
+			died := now
+			for now.Sub(died) < time.Duration(cfg.MissedLimit+1)*cfg.Interval {
+				now = now.Add(100 * time.Millisecond)
+				list.observe(m, now)
+				m.Check(now)
+			}
+			s := m.Check(now)
+			if !s.Lost {
+				t.Fatalf("not lost %v after death: %+v", now.Sub(died), s)
+			}
+			if s.LastSeq != last {
+				t.Fatalf("last seq %d after death, want %d", s.LastSeq, last)
+			}
+			if s.MissedTotal != 0 {
+				t.Fatalf("missed total %d, want 0", s.MissedTotal)
+			}
+		})
+	}
+}
+
+func TestMonitorIsNew(t *testing.T) {
+	t0 := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	m := NewMonitor(DefaultConfig, t0)
+	m.Observe(Beat{Seq: 5, TS: t0}, t0)
+
+	tests := []struct {
+		name string
+		beat Beat
+		want bool
+	}{
+		{"next seq", Beat{Seq: 6, TS: t0.Add(time.Second)}, true},
+		{"repeat", Beat{Seq: 5, TS: t0}, false},
+		{"restart", Beat{Seq: 1, TS: t0.Add(time.Second)}, true},
+		{"higher seq from before", Beat{Seq: 9, TS: t0.Add(-time.Second)}, false},
+		{"same ts", Beat{Seq: 6, TS: t0}, false},
+		{"no seq", Beat{TS: t0.Add(time.Second)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := m.IsNew(tt.beat); got != tt.want {
+				t.Fatalf("IsNew(%+v) = %v, want %v", tt.beat, got, tt.want)
+			}
+		})
+	}
+}
+
+func seqs(from, to uint64) []uint64 {
+	var s []uint64
+	for i := from; i <= to; i++ {
+		s = append(s, i)
+	}
+	return s
+}
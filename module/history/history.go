@@ -0,0 +1,162 @@
+package history
+
+import (
+	"communication_module/transport"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Module history: every event and reply sent on MODULE_Q is also appended
+// to a capped stream, so a host that connects late can backfill and a fault
+// can be analysed afterwards. The stream entry ID is the time it was
+// recorded (ms), which is what time range queries use.
+
+// Config of the history stream
+type Config struct {
+	Stream string
+	MaxLen int64 // About this many entries are kept, older ones are trimmed
+}
+
+// STATUS alone is one entry a second, 10000 entries are about 2.5 hours
+var DefaultConfig = Config{Stream: "MODULE_HISTORY", MaxLen: 10000}
+
+// ConfigFromEnv overrides cfg with MODULE_HISTORY_MAXLEN
+func ConfigFromEnv(cfg Config) (Config, error) {
+	if v := os.Getenv("MODULE_HISTORY_MAXLEN"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			return cfg, fmt.Errorf("MODULE_HISTORY_MAXLEN: invalid length %q", v)
+		}
+		cfg.MaxLen = n
+	}
+	return cfg, nil
+}
+
+var ErrNoStreams = errors.New("history: transport has no streams")
+
+// Entry of the history
+type Entry struct {
+	ID            string          `json:"id"`
+	Time          time.Time       `json:"time"`
+	Kind          string          `json:"kind"`                     // Event message (STATUS, FAULT ...) or REPLY
+	Status        string          `json:"status,omitempty"`         // Status of a reply
+	MsgID         string          `json:"msg_id"`                   // Host command of a reply, own id of an event
+	CorrelationID string          `json:"correlation_id,omitempty"` // Host command an event relates to
+	Payload       json.RawMessage `json:"payload"`                  // As published
+}
+
+// Log reads and writes the history stream
+type Log struct {
+	streams transport.Streams
+	cfg     Config
+}
+
+func New(tr transport.Transport, cfg Config) (*Log, error) {
+	streams, ok := tr.(transport.Streams)
+	if !ok {
+		return nil, ErrNoStreams
+	}
+	return &Log{streams: streams, cfg: cfg}, nil
+}
+
+// Record appends e (ID and Time are set by the stream)
+func (l *Log) Record(ctx context.Context, e Entry) error {
+	values := map[string]string{
+		"kind":    e.Kind,
+		"msg_id":  e.MsgID,
+		"payload": string(e.Payload),
+	}
+	if e.Status != "" {
+		values["status"] = e.Status
+	}
+	if e.CorrelationID != "" {
+		values["correlation_id"] = e.CorrelationID
+	}
+	if _, err := l.streams.XAdd(ctx, l.cfg.Stream, l.cfg.MaxLen, values); err != nil {
+		return fmt.Errorf("history record: %w", err)
+	}
+	return nil
+}
+
+// Range returns up to limit entries recorded between from and to, oldest
+// first. A zero from is the start of the history, a zero to is now.
+func (l *Log) Range(ctx context.Context, from, to time.Time, limit int64) ([]Entry, error) {
+	start, stop := "-", "+"
+	if !from.IsZero() {
+		start = strconv.FormatInt(from.UnixMilli(), 10)
+	}
+	if !to.IsZero() {
+		stop = strconv.FormatInt(to.UnixMilli(), 10)
+	}
+	raw, err := l.streams.XRange(ctx, l.cfg.Stream, start, stop, limit)
+	if err != nil {
+		return nil, fmt.Errorf("history range: %w", err)
+	}
+	entries := make([]Entry, 0, len(raw))
+	for _, r := range raw {
+		entries = append(entries, parse(r))
+	}
+	return entries, nil
+}
+
+// pageSize of the scan for ByMsgID
+const pageSize = 500
+
+// ByMsgID returns up to limit entries about the host command msgID (its
+// replies and the events correlated with it), oldest first. The stream has
+// no index so this scans it, which the MaxLen cap keeps bounded.
+func (l *Log) ByMsgID(ctx context.Context, msgID string, limit int64) ([]Entry, error) {
+	var entries []Entry
+	start := "-"
+	for {
+		raw, err := l.streams.XRange(ctx, l.cfg.Stream, start, "+", pageSize)
+		if err != nil {
+			return entries, fmt.Errorf("history scan: %w", err)
+		}
+		for _, r := range raw {
+			if r.Values["msg_id"] != msgID && r.Values["correlation_id"] != msgID {
+				continue
+			}
+			entries = append(entries, parse(r))
+			if limit > 0 && int64(len(entries)) >= limit {
+				return entries, nil
+			}
+		}
+		if len(raw) < pageSize {
+			return entries, nil
+		}
+		start = next(raw[len(raw)-1].ID)
+	}
+}
+
+// next is the smallest ID after id
+func next(id string) string {
+	ms, seq, _ := strings.Cut(id, "-")
+	n, _ := strconv.ParseInt(seq, 10, 64)
+	return ms + "-" + strconv.FormatInt(n+1, 10)
+}
+
+func parse(r transport.StreamEntry) Entry {
+	e := Entry{
+		ID:            r.ID,
+		Kind:          r.Values["kind"],
+		Status:        r.Values["status"],
+		MsgID:         r.Values["msg_id"],
+		CorrelationID: r.Values["correlation_id"],
+		Payload:       json.RawMessage(r.Values["payload"]),
+	}
+	ms, _, _ := strings.Cut(r.ID, "-")
+	if n, err := strconv.ParseInt(ms, 10, 64); err == nil {
+		e.Time = time.UnixMilli(n).UTC()
+	}
+	if !json.Valid(e.Payload) {
+		e.Payload = nil
+	}
+	return e
+}
@@ -0,0 +1,128 @@
+package logger
+
+import (
+	"communication_module/transport"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Replies held back by DELAY_REPLIES. Each channel has one queue, published
+// in the order the replies were sent by a single goroutine, so a jittered
+// delay never lets a RESULT overtake its ACK. While a channel has replies
+// held back, undelayed replies queue behind them for the same reason.
+
+type delayedReply struct {
+	tr   transport.Transport
+	data []byte
+	due  time.Time
+}
+
+type delayQueue struct {
+	items []delayedReply // Head is published next, guarded by delays.mu
+}
+
+var delays = struct {
+	mu        sync.Mutex
+	queues    map[string]*delayQueue
+	flush     chan struct{} // Closed by FlushDelayed, publish without waiting
+	abandoned bool          // FlushDelayed gave up, the rest is dropped
+}{
+	queues: map[string]*delayQueue{},
+	flush:  make(chan struct{}),
+}
+
+// enqueue holds data back for delay, or behind the replies already held
+// back on channel. False if the reply can be published right away.
+func enqueue(tr transport.Transport, channel string, data []byte, delay time.Duration) bool {
+	delays.mu.Lock()
+	defer delays.mu.Unlock()
+	q, pending := delays.queues[channel]
+	if delay <= 0 && !pending {
+		return false
+	}
+	if !pending {
+		q = &delayQueue{}
+		delays.queues[channel] = q
+		go q.run(channel)
+	}
+	q.items = append(q.items, delayedReply{tr: tr, data: data, due: time.Now().Add(delay)})
+	return true
+}
+
+// run publishes the queue of channel in order until it is empty
+func (q *delayQueue) run(channel string) {
+	for {
+		delays.mu.Lock()
+		if len(q.items) == 0 {
+			delete(delays.queues, channel)
+			delays.mu.Unlock()
+			return
+		}
+		// The head stays queued until published, a reply sent meanwhile
+		// must see the channel as pending
+		head, flush := q.items[0], delays.flush
+		delays.mu.Unlock()
+
+		timer := time.NewTimer(time.Until(head.due))
+		select {
+		case <-timer.C:
+		case <-flush:
+		}
+		timer.Stop()
+
+		delays.mu.Lock()
+		abandoned := delays.abandoned
+		delays.mu.Unlock()
+		if !abandoned {
+			ctx, cancel := context.WithTimeout(context.Background(), DelayedPublishTimeout)
+			publish(ctx, head.tr, channel, head.data)
+			cancel()
+		}
+
+		delays.mu.Lock()
+		q.items = q.items[1:]
+		delays.mu.Unlock()
+	}
+}
+
+// FlushDelayed publishes the held back replies now, in order, and waits
+// until they are out. Called before the transport is closed. If ctx ends
+// first the rest is dropped.
+func FlushDelayed(ctx context.Context) error {
+	delays.mu.Lock()
+	close(delays.flush)
+	delays.mu.Unlock()
+	defer func() {
+		delays.mu.Lock()
+		delays.flush = make(chan struct{})
+		delays.mu.Unlock()
+	}()
+
+	// A queue is removed once it is empty
+	ticker := time.NewTicker(5 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		delays.mu.Lock()
+		n := 0
+		for _, q := range delays.queues {
+			n += len(q.items)
+		}
+		if n == 0 {
+			delays.mu.Unlock()
+			return nil
+		}
+		if ctx.Err() != nil {
+			delays.abandoned = true
+			delays.mu.Unlock()
+			return fmt.Errorf("dropped %d delayed replies: %w", n, ctx.Err())
+		}
+		delays.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+		}
+	}
+}
@@ -1,13 +1,12 @@
 package logger
 
 import (
+	"communication_module/history"
+	"communication_module/protocol"
+	"communication_module/transport"
 	"context"
-	"encoding/json"
 	"fmt"
-	"maps"
 	"time"
-
-	"github.com/redis/go-redis/v9"
 )
 
 // Handler is a callback for processing each Pub/Sub message.
@@ -60,50 +59,139 @@ func Plain(a ...interface{}) {
 	fmt.Print(a...)
 }
 
-func PubModuleQ(
+// Encoder turns replies and events into the wire format of a channel.
+// History always keeps JSON.
+type Encoder interface {
+	EncodeReply(channel string, r protocol.Reply) ([]byte, error)
+	EncodeEvent(channel string, e protocol.Event) ([]byte, error)
+}
+
+var encoder Encoder
+
+// SetEncoder installs the encoder of PubEvent and PubReply, nil is JSON
+func SetEncoder(e Encoder) {
+	encoder = e
+}
+
+// PubEvent publishes a module originated event (STATUS, WARNING, FAULT ...)
+// on the channel (MODULE_Q by default)
+func PubEvent(
 	ctx context.Context,
-	rdb *redis.Client,
-	message string,
+	tr transport.Transport,
+	event protocol.Event,
 	system_state map[string]interface{},
-	channel string,
-	payload_input map[string]interface{}) (int64, error) {
-
-	// Let's try logging to redis
-	// Publish JSON to channel
-	// Example payload
+	channel string) (int64, error) {
 
 	if channel == "" {
 		channel = "MODULE_Q"
 	}
+	event.SystemState = system_state
+	event = event.Stamp()
 	Plain("Publishing to channel:", channel)
 
-	// Example payload
-	payload := map[string]interface{}{
-		"msg_id": "12345",
-		//"action":       "HEALTH_CHECK",
-		"system_state": system_state,
-		"message":      message,
-		"msg_time":     time.Now().Format(time.RFC3339),
-		//"body": map[string]interface{}{
-		//	"battery": 85,
-		//	"temp":    42.5,
-		//},
+	data, err := protocol.MarshalEvent(event)
+	if err != nil {
+		Error("event marshal error:", err)
+		return 0, err
+	}
+	record(ctx, history.Entry{Kind: event.Message, MsgID: event.MsgID, CorrelationID: event.CorrelationID, Payload: data})
+	if encoder != nil {
+		if data, err = encoder.EncodeEvent(channel, event); err != nil {
+			Error("event encode error:", err)
+			return 0, err
+		}
 	}
 
-	// Merge payload_input into payload
-	// Overwrite or add new keys
-	maps.Copy(payload, payload_input)
+	return publish(ctx, tr, channel, data)
+}
 
-	// Marshal to JSON
-	data, err := json.Marshal(payload)
+// ReplyFilter decides whether a reply is dropped or published late, set by
+// fault injection (DROP_REPLIES, DELAY_REPLIES)
+type ReplyFilter func() (drop bool, delay time.Duration)
+
+var replyFilter ReplyFilter
+
+// SetReplyFilter installs the filter run before every PubReply
+func SetReplyFilter(f ReplyFilter) {
+	replyFilter = f
+}
+
+var historyLog *history.Log
+
+// SetHistory makes PubEvent and PubReply also record to the history, nil stops it
+func SetHistory(h *history.Log) {
+	historyLog = h
+}
+
+func record(ctx context.Context, e history.Entry) {
+	if historyLog == nil {
+		return
+	}
+	if err := historyLog.Record(ctx, e); err != nil {
+		Error("history error:", err)
+	}
+}
+
+// PubReply publishes a typed reply to a command on the channel (MODULE_Q by default)
+func PubReply(
+	ctx context.Context,
+	tr transport.Transport,
+	reply protocol.Reply,
+	system_state map[string]interface{},
+	channel string) (int64, error) {
+
+	if channel == "" {
+		channel = "MODULE_Q"
+	}
+	reply.SystemState = system_state
+	reply = reply.Stamp()
+	Plain("Publishing reply to channel:", channel, " ", reply.Cmd, " ", reply.Status, " ", reply.Reason)
+
+	data, err := protocol.MarshalReply(reply)
 	if err != nil {
-		fmt.Println("json marshal error:", err)
-		return 0, fmt.Errorf("json marshal: %w", err)
+		Error("reply marshal error:", err)
+		return 0, err
 	}
+	// Recorded even when fault injection drops it, the module did send it
+	if !reply.Ephemeral {
+		record(ctx, history.Entry{Kind: string(reply.Type), Status: string(reply.Status), MsgID: reply.MsgID, Payload: data})
+	}
+
+	if encoder != nil {
+		if data, err = encoder.EncodeReply(channel, reply); err != nil {
+			Error("reply encode error:", err)
+			return 0, err
+		}
+	}
+
+	var delay time.Duration
+	if replyFilter != nil {
+		var drop bool
+		drop, delay = replyFilter()
+		if drop {
+			Warning("Fault injection: dropping reply ", reply.Cmd, " ", reply.Status)
+			return 0, nil
+		}
+	}
+	// Published later, in order, by the queue of the channel (see
+	// FlushDelayed). The caller (a command running, the intake) goes on
+	// as if the link were slow.
+	if delay > 0 {
+		Warning("Fault injection: delaying reply ", reply.Cmd, " ", reply.Status, " by ", delay)
+	}
+	if enqueue(tr, channel, data, delay) {
+		return 0, nil
+	}
+	return publish(ctx, tr, channel, data)
+}
+
+// DelayedPublishTimeout bounds the publish of a reply delayed by fault injection
+var DelayedPublishTimeout = 5 * time.Second
 
-	n, err := rdb.Publish(ctx, channel, data).Result()
+func publish(ctx context.Context, tr transport.Transport, channel string, data []byte) (int64, error) {
+	n, err := tr.Publish(ctx, channel, string(data))
 	if err != nil {
-		Error("redis publish error:", err)
+		Error("publish error:", err)
 		return n, fmt.Errorf("publish: %w", err)
 	}
 	Info("published to", channel, "subs:", n)
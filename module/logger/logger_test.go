@@ -0,0 +1,130 @@
+package logger
+
+import (
+	"communication_module/protocol"
+	"communication_module/transport"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPubReplyFilter(t *testing.T) {
+	tests := []struct {
+		name      string
+		drop      bool
+		delay     time.Duration
+		delivered bool
+	}{
+		{name: "no fault", delivered: true},
+		{name: "dropped", drop: true},
+		{name: "delayed", delay: 100 * time.Millisecond, delivered: true},
+	}
+	defer SetReplyFilter(nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetReplyFilter(func() (bool, time.Duration) { return tt.drop, tt.delay })
+			tr := transport.NewMemory(10)
+			sub, err := tr.Subscribe(context.Background(), "MODULE_Q")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer sub.Close()
+
+			// The command is already gone, a delayed reply is still published
+			ctx, cancel := context.WithCancel(context.Background())
+			start := time.Now()
+			if _, err := PubReply(ctx, tr, protocol.NewReply("m-1", "PING", protocol.RESULT, "pong"), nil, "MODULE_Q"); err != nil {
+				t.Fatal(err)
+			}
+			cancel()
+			if took := time.Since(start); took > tt.delay/2+10*time.Millisecond {
+				t.Fatalf("PubReply blocked for %v", took)
+			}
+
+			select {
+			case msg := <-sub.Messages():
+				if !tt.delivered {
+					t.Fatalf("dropped reply delivered: %s", msg.Payload)
+				}
+				if took := time.Since(start); took < tt.delay {
+					t.Fatalf("delivered after %v, want %v", took, tt.delay)
+				}
+			case <-time.After(tt.delay + 500*time.Millisecond):
+				if tt.delivered {
+					t.Fatal("reply not delivered")
+				}
+			}
+		})
+	}
+}
+
+func TestDelayedRepliesKeepOrder(t *testing.T) {
+	// Jitter: every reply is delayed less than the one before, the last not at all
+	statuses := []protocol.Status{protocol.ACK, protocol.ACCEPTED, protocol.PROGRESS, protocol.PROGRESS, protocol.RESULT}
+	delay := 100 * time.Millisecond
+	SetReplyFilter(func() (bool, time.Duration) {
+		delay -= 25 * time.Millisecond
+		return false, delay
+	})
+	defer SetReplyFilter(nil)
+
+	tr := transport.NewMemory(10)
+	sub, err := tr.Subscribe(context.Background(), "MODULE_Q")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+	for i, status := range statuses {
+		reply := protocol.NewReply("m-1", "PERFORM_MANEUVER", status, "")
+		reply.Data["seq"] = i
+		if _, err := PubReply(context.Background(), tr, reply, nil, "MODULE_Q"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := range statuses {
+		select {
+		case msg := <-sub.Messages():
+			r, err := protocol.UnmarshalReply([]byte(msg.Payload))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if seq := r.Data["seq"]; seq != float64(i) {
+				t.Fatalf("reply %d was %v (%s), want %d", i, seq, r.Status, i)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("reply %d not delivered", i)
+		}
+	}
+}
+
+func TestFlushDelayed(t *testing.T) {
+	SetReplyFilter(func() (bool, time.Duration) { return false, time.Hour })
+	defer SetReplyFilter(nil)
+
+	tr := transport.NewMemory(10)
+	sub, err := tr.Subscribe(context.Background(), "MODULE_Q")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+	for _, status := range []protocol.Status{protocol.ACK, protocol.RESULT} {
+		PubReply(context.Background(), tr, protocol.NewReply("m-1", "PING", status, ""), nil, "MODULE_Q")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := FlushDelayed(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []protocol.Status{protocol.ACK, protocol.RESULT} {
+		select {
+		case msg := <-sub.Messages():
+			if r, _ := protocol.UnmarshalReply([]byte(msg.Payload)); r.Status != want {
+				t.Fatalf("flushed %s, want %s", r.Status, want)
+			}
+		default:
+			t.Fatalf("%s not published by the flush", want)
+		}
+	}
+}
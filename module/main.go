@@ -1,11 +1,15 @@
 package main
 
 import (
-	"communication_module/command"
+	"communication_module/codec"
+	"communication_module/dedup"
+	"communication_module/heartbeat"
+	"communication_module/history"
 	"communication_module/logger"
+	"communication_module/protocol"
 	"communication_module/pubsub"
 	"communication_module/state"
-	"math/rand"
+	"communication_module/transport"
 	"os/signal"
 	"syscall"
 
@@ -22,66 +26,97 @@ import (
 
 // LOCALS -------------------------------------------------
 var ms state.ModuleState
-var lastHeartbeats []string
-var unchangedTicks int
+var hostBeats *heartbeat.Monitor
+var lastMissed int
+var dedupStore *dedup.Store
+var encodings codec.Channels
 
 //---------------------------------------------------------
 
 //var ms *state.ModuleState
-//var ms := state.Initialize()
-
-// startHeartbeat launches a 500ms heartbeat that logs to stdout and Redis.
-// It SETs "heartbeat:latest" with a TTL and also PUBLISHes on "heartbeat".
-func startHeartbeat(ctx context.Context, rdb *redis.Client, quit <-chan struct{}) {
-	const interval = 500 * time.Millisecond
-	hb := time.NewTicker(interval)
+//var ms := state.Initialize(state.DefaultConfig)
 
+// startHeartbeat publishes the module heartbeat (seq, status, uptime) until quit.
+// It SETs cfg.Key with cfg.TTL and also PUBLISHes on cfg.Channel.
+func startHeartbeat(ctx context.Context, tr transport.Transport, cfg heartbeat.PublisherConfig, ms *state.ModuleState, quit <-chan struct{}) {
+	ctx, cancel := context.WithCancel(ctx)
 	go func() {
-		defer hb.Stop()
-		seq := 0
-		for {
-			select {
-			case <-quit:
-				fmt.Println("\n\033[36m[Heartbeat] stopped\033[0m")
-				return
-			case t := <-hb.C:
-				seq++
-				ts := t.Format(time.RFC3339Nano)
-				payload := fmt.Sprintf(`{"seq":%d,"ts":"%s"}`, seq, ts)
-
-				// Echo to console
-				fmt.Printf("\n\033[36m[Heartbeat] #%d %s\033[0m", seq, ts)
-
-				// Update Redis "latest" with a TTL slightly > interval (for liveness checks)
-				if err := rdb.Set(ctx, "heartbeat:latest", payload, 2*interval).Err(); err != nil {
-					fmt.Printf("\n\033[31m[Heartbeat->Redis SET error] %v\033[0m", err)
-				}
-
-				// Publish to a channel for listeners (optional but handy)
-				if err := rdb.Publish(ctx, "heartbeat", payload).Err(); err != nil {
-					fmt.Printf("\n\033[31m[Heartbeat->Redis PUBLISH error] %v\033[0m", err)
-				}
-
-				// (Optional) keep a rolling log in Redis
-				// _ = rdb.LPush(ctx, "heartbeat:log", payload).Err()
-				// _ = rdb.LTrim(ctx, "heartbeat:log", 0, 199).Err()
-			}
-		}
+		<-quit
+		cancel()
+		fmt.Println("\n\033[36m[Heartbeat] stopped\033[0m")
 	}()
+
+	status := func() string { return string(ms.Current()) }
+	go heartbeat.Publish(ctx, tr, cfg, status, func(err error) {
+		fmt.Printf("\n\033[31m[Heartbeat->Transport error] %v\033[0m", err)
+	})
+}
+
+// newTransport connects to Redis (default) or, with MODULE_TRANSPORT=memory,
+// runs on an in-process transport without a host
+func newTransport(kind string) (transport.Transport, error) {
+	switch kind {
+	case "", "redis":
+		addr := os.Getenv("MODULE_REDIS_ADDR")
+		if addr == "" {
+			addr = "localhost:6379"
+		}
+		return transport.NewRedis(redis.NewClient(&redis.Options{Addr: addr}), 1024), nil
+	case "memory":
+		logger.Warning("In-memory transport: no host can reach this module")
+		return transport.NewMemory(1024), nil
+	}
+	return nil, fmt.Errorf("MODULE_TRANSPORT: unknown transport %q (redis, memory)", kind)
 }
 
 func main() {
 
 	// Initialize module state
-	ms := state.Initialize()
+	ms := state.Initialize(state.DefaultConfig)
 
-	// Context for Redis ops
+	// Context for transport ops
 	// --------- [START Redis Connection] ---------
 	ctx := context.Background()
-	rdb := redis.NewClient(&redis.Options{
-		Addr: "localhost:6379",
-	})
-	defer rdb.Close()
+	tr, err := newTransport(os.Getenv("MODULE_TRANSPORT"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer tr.Close()
+	// Replies held back by DELAY_REPLIES go out before the transport closes
+	defer func() {
+		ctx, cancel := context.WithTimeout(ctx, 2*logger.DelayedPublishTimeout)
+		defer cancel()
+		if err := logger.FlushDelayed(ctx); err != nil {
+			logger.Error("Delayed replies: ", err)
+		}
+	}()
+
+	// Publish every state transition to the host
+	ms.PublishTransitions(ctx, tr)
+
+	// Injected DROP_REPLIES / DELAY_REPLIES faults act on every reply
+	logger.SetReplyFilter(ms.Faults().Reply)
+
+	// Wire encoding per channel, JSON unless MODULE_ENCODING says otherwise
+	if encodings, err = codec.ChannelsFromEnv(); err != nil {
+		log.Fatal(err)
+	}
+	logger.SetEncoder(encodings)
+
+	// Keep every event and reply in the capped history stream as well
+	histCfg, err := history.ConfigFromEnv(history.DefaultConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if hist, err := history.New(tr, histCfg); err != nil {
+		logger.Warning("No module history: ", err)
+	} else {
+		logger.SetHistory(hist)
+		ms.SetHistory(hist)
+	}
+
+	// Remember msg_ids for 10 minutes (at most 1000) to answer retries idempotently
+	dedupStore = dedup.NewStore(tr, 10*time.Minute, 1000)
 	// --------- [END Redis Connection] ---------
 
 	// Put terminal in raw mode so single keypresses are delivered immediately
@@ -132,17 +167,40 @@ func main() {
 
 	// Timers etc
 	// --------- [TIMERS and HEARTBEAT] ---------
-	ticker_status := time.NewTicker(1000 * time.Millisecond)
-	ticker_heartbeat := time.NewTicker(500 * time.Millisecond)
+	statusInterval := 1000 * time.Millisecond
+	ticker_status := time.NewTicker(statusInterval)
+	// Poll the host beats twice per beat interval
+	hostBeats = heartbeat.NewMonitor(heartbeat.DefaultConfig, time.Now())
+	ticker_heartbeat := time.NewTicker(hostBeats.Config().Interval / 2)
 	defer ticker_status.Stop()
 	defer ticker_heartbeat.Stop()
 
-	// Start heartbeat
-	////go heartbeat(ctx, rdb, "module:heartbeat", 5000*time.Millisecond)
+	// Start the module heartbeat, interval and TTL from the environment
+	beatCfg, err := heartbeat.PublisherConfigFromEnv(heartbeat.DefaultPublisher)
+	if err != nil {
+		log.Fatalf("heartbeat config: %v", err)
+	}
+	logger.Info(fmt.Sprintf("Module heartbeat every %s on %s (TTL %s)", beatCfg.Interval, beatCfg.Key, beatCfg.TTL))
+	startHeartbeat(ctx, tr, beatCfg, ms, quit)
 	// --------- [END TIMERS and HEARTBEAT] ---------
 
 	// --------- [START Pub Sub: Command] ---------
-	stop, err := pubsub.SubscribeAsync(ctx, rdb, []string{"CMD_Q"}, 4, 1024, ms, recieveCommand)
+	// MODULE_INTAKE=streams reads commands from a Redis Stream (at least
+	// once), the default is the CMD_Q Pub/Sub channel
+	var stop func()
+	switch intake := os.Getenv("MODULE_INTAKE"); intake {
+	case "", "pubsub":
+		stop, err = pubsub.SubscribeAsync(ctx, tr, []string{"CMD_Q"}, 4, ms, recieveCommand)
+	case "streams":
+		var streamCfg pubsub.StreamConfig
+		if streamCfg, err = pubsub.StreamConfigFromEnv(pubsub.DefaultStream); err != nil {
+			break
+		}
+		logger.Info(fmt.Sprintf("Reading commands from stream %s as %s/%s", streamCfg.Stream, streamCfg.Group, streamCfg.Consumer))
+		stop, err = pubsub.ConsumeStream(ctx, tr, streamCfg, 4, ms, recieveCommand)
+	default:
+		err = fmt.Errorf("MODULE_INTAKE: unknown intake %q (pubsub, streams)", intake)
+	}
 	if err != nil {
 		log.Fatalf("failed to subscribe: %v", err)
 	}
@@ -158,136 +216,122 @@ func main() {
 			return
 
 		case <-ticker_status.C:
-			pong, err := rdb.Ping(ctx).Result()
-			if err != nil {
-				fmt.Println("\nCould not connect to Redis:", err)
+			if err := tr.Ping(ctx); err != nil {
+				fmt.Println("\nCould not reach the transport:", err)
 				return
 			}
-			logger.Plain("Redis connected: ", pong, "    ")
-			//ms_state_repr, err := state.StructToMap(ms)
-			ms_state_repr := state.StructToMap(ms)
+			logger.Plain("Transport connected: PONG    ")
+			//ms_state_repr, err := ms.Snapshot()
+			ms_state_repr := ms.Snapshot()
 			//if err != nil {
 			//	fmt.Println("Error converting state to struct: ", err)
 			//}
-			// Let's also get battery and temperature to vary here at random
-			ms.BatteryLevel = ms.BatteryLevel - (rand.Int63n(10) - 5)
-			ms.Temperature = ms.Temperature - (rand.Float64()*10.0 - 5.0)
-			// Randomly clamp values to be reasonable
-			if ms.BatteryLevel < 0 {
-				ms.BatteryLevel = 0
-			}
-			if ms.BatteryLevel > 100 {
-				ms.BatteryLevel = 80
+			// Thermal and power models, orbit
+			for _, reason := range ms.Tick(statusInterval) {
+				fault := protocol.NewEvent("FAULT", "")
+				fault.Reason = reason
+				logger.PubEvent(ctx, tr, fault, ms.Snapshot(), "MODULE_Q")
 			}
-			if ms.Temperature < -50 {
-				ms.Temperature = -30
-			}
-			if ms.Temperature > 100 {
-				ms.Temperature = 80
-			}
-			logger.PubModuleQ(ctx, rdb, "STATUS", ms_state_repr, "MODULE_Q", map[string]interface{}{})
+			logger.PubEvent(ctx, tr, protocol.NewEvent("STATUS", ""), ms_state_repr, "MODULE_Q")
 
-		case <-ticker_heartbeat.C:
-			// Query last 10 host heartbeats
-			logger.Plain("Checking for host heartbeat")
-			heartbeats, err := rdb.LRange(ctx, "HOST_HEARTBEAT", 0, 9).Result()
-			if err != nil {
+		case now := <-ticker_heartbeat.C:
+			if err := readHostBeats(ctx, tr, ms, now); err != nil {
 				logger.Error("Error querying HOST_HEARTBEAT:", err)
 				continue
 			}
-
-			// Check if heartbeats have changed
-			if len(heartbeats) == len(lastHeartbeats) {
-				unchanged := true
-				for i := range heartbeats {
-					if heartbeats[i] != lastHeartbeats[i] {
-						unchanged = false
-						break
-					}
-				}
-				if unchanged {
-					unchangedTicks++
-				} else {
-					unchangedTicks = 0
-				}
-			}
-
-			lastHeartbeats = heartbeats
-			// React if unchanged for 3 ticks
-			if unchangedTicks > 3 {
-				logger.Error(
-					fmt.Sprintf("Host heartbeat has not updated for %d ticks!", unchangedTicks),
-				)
-				// Set System state to FAULT
-				ms.Status = "SAFE"
-				ms.LastUpdated = time.Now().Unix()
-				ms_state_repr := state.StructToMap(ms)
-				logger.PubModuleQ(ctx, rdb, "FAULT", ms_state_repr, "MODULE_Q", map[string]interface{}{})
-
-				//new code
-				return_payload := []string{}
-				return_map := map[string]interface{}{}
-
-				return_map["type"] = "RET_VALUE"
-				return_payload = append(return_payload, fmt.Sprintf("Missed %d heartbeats. Taking SAFE mode", unchangedTicks))
-				return_map["return_params"] = return_payload
-				logger.PubModuleQ(ctx, rdb, "Can not resume panel operations", state.StructToMap(ms), "MODULE_Q", return_map)
-
-			} else if unchangedTicks == 0 {
-				logger.Plain("Host heartbeat is healthy.")
-				// Was the system in fault?
-				if ms.Status == "SAFE" {
-					logger.Info("System has recovered from fault.")
-					// ms.SetField("Status", "IDLE")   // This is nice, but too much generalization?
-					ms.SetStatus("IDLE")
-				}
-				// Set System state indicate healthy
-				ms.LastUpdated = time.Now().Unix()
-				//ms_state_repr := state.StructToMap(ms)
-			} else if unchangedTicks > 0 && unchangedTicks <= 3 {
-				// Warning state
-				ms.LastUpdated = time.Now().Unix()
-				ms_state_repr := state.StructToMap(ms)
-				logger.Info(
-					fmt.Sprintf("Host heartbeat unchanged for %d ticks.", unchangedTicks),
-				)
-				logger.PubModuleQ(ctx, rdb, "WARNING", ms_state_repr, "MODULE_Q", map[string]interface{}{})
-			}
+			link := hostBeats.Check(now)
+			ms.HostLink(ctx, tr, link, lastMissed)
+			lastMissed = link.Missed
 		}
 	}
 	// --------- [END Main Loop] ---------
 
 } // End of func main()
 
-func heartbeat(ctx context.Context, rdb *redis.Client, key string, interval time.Duration) {
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case t := <-ticker.C:
-			err := rdb.Set(ctx, key, t.Format(time.RFC3339), interval*5).Err()
-			if err != nil {
-				fmt.Println("failed to write heartbeat:", err)
-			} else {
-				fmt.Println("\r\nheartbeat logged at", t)
-			}
+// readHostBeats feeds the new host beats of HOST_HEARTBEAT (newest first)
+// to the monitor, oldest first
+func readHostBeats(ctx context.Context, tr transport.Transport, ms *state.ModuleState, now time.Time) error {
+	entries, err := tr.LRange(ctx, "HOST_HEARTBEAT", 0, 9)
+	if err != nil {
+		return err
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		beat, err := heartbeat.Parse(entries[i])
+		if err != nil {
+			logger.Warning("Ignoring host heartbeat: ", err)
+			continue
+		}
+		if !hostBeats.IsNew(beat) {
+			continue
 		}
+		if ms.Faults().SkipBeat() {
+			// Injected PAUSE_HEARTBEAT: the beat counts as missed
+			logger.Warning("Fault injection: ignoring host heartbeat ", beat.Seq)
+			hostBeats.Skip(beat)
+			continue
+		}
+		hostBeats.Observe(beat, now)
 	}
+	return nil
 }
 
-func recieveCommand(ctx context.Context, rdb *redis.Client, channel, payload string, ms *state.ModuleState) error {
+func recieveCommand(ctx context.Context, tr transport.Transport, channel, payload string, ms *state.ModuleState) error {
 	// Handle the incoming command
 	log.Printf("Received command on %s: %s", channel, payload)
 
-	cmd := command.ParseCommand(payload)
+	cmd, err := encodings.DecodeCommand(channel, payload)
+	if err != nil {
+		logger.Error("Could not parse command: ", err)
+		reply := protocol.Fail(cmd.MSG_ID, cmd.CMD, protocol.MALFORMED_PAYLOAD, err.Error())
+		reply.Data["error"] = err.Error()
+		logger.PubReply(ctx, tr, reply, ms.Snapshot(), "MODULE_Q")
+		return fmt.Errorf("%w: %v", pubsub.ErrUndeliverable, err)
+	}
 	logger.Info("Parsed Command: ", cmd)
+
+	// Duplicate msg_id: answer from the dedup cache without running it again
+	entry, dup, err := dedupStore.Begin(ctx, cmd.MSG_ID, cmd.CMD)
+	if err != nil {
+		logger.Error("Dedup store unavailable, processing anyway: ", err)
+	} else if dup {
+		logger.Warning("Duplicate msg_id ", cmd.MSG_ID, " (", entry.State, ")")
+		if _, err := logger.PubReply(ctx, tr, dedup.DuplicateReply(entry), ms.Snapshot(), "MODULE_Q"); err != nil {
+			return err
+		}
+		if entry.State != dedup.DONE {
+			// Not finished yet, a stream entry stays pending until it is
+			return fmt.Errorf("msg_id %s still in flight", cmd.MSG_ID)
+		}
+		return nil
+	}
+
+	// ACK straight away so the host can tell a slow command from a lost one
+	logger.PubReply(ctx, tr, protocol.NewReply(cmd.MSG_ID, cmd.CMD, protocol.ACK, "Command received"), ms.Snapshot(), "MODULE_Q")
 	logger.Error("Command Counter: ", cmd.CMD_COUNTER)
 
-	fmt.Print(ms)
-	state.ProcessCommand(cmd, ms, ctx, rdb)
+	// Keep the lease while running, another module takes over once it expires
+	release := func() {}
+	if err == nil {
+		release = dedupStore.Hold(ctx, cmd.MSG_ID)
+	}
+	reply := state.ProcessCommand(cmd, ms, ctx, tr)
+	release()
+	if err != nil || !reply.IsFinal() || reply.Reason == protocol.ALREADY_IN_FLIGHT {
+		// The run already in flight owns the entry
+		return nil
+	}
+	// Like the final reply, stored even if the command used up ctx
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), state.FinalReplyTimeout)
+	defer cancel()
+	// Only outcomes a retry shouldn't change are kept for duplicates
+	if dedup.Cacheable(reply) {
+		err = dedupStore.Complete(ctx, cmd.MSG_ID, reply)
+	} else {
+		err = dedupStore.Forget(ctx, cmd.MSG_ID)
+	}
+	if err != nil {
+		logger.Error("Could not store reply for ", cmd.MSG_ID, ": ", err)
+	}
 	return nil
 
 }
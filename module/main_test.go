@@ -0,0 +1,122 @@
+package main
+
+import (
+	"communication_module/dedup"
+	"communication_module/protocol"
+	"communication_module/state"
+	"communication_module/transport"
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRecieveCommandErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		msgID   string // Echoed on the reply
+		status  protocol.Status
+		reason  protocol.Reason
+	}{
+		{name: "unknown command", payload: `{"msg_id": "m-1", "CMD": "WARP_DRIVE"}`, msgID: "m-1", status: protocol.ERROR, reason: protocol.UNRECOGNIZED_COMMAND},
+		{name: "not json", payload: `{"msg_id": "m-2", "CMD": "HEALTH_CHECK"`, msgID: "m-2", status: protocol.ERROR, reason: protocol.MALFORMED_PAYLOAD},
+		{name: "wrong type", payload: `{"msg_id": "m-3", "CMD": "HEALTH_CHECK", "CMD_COUNTER": "one"}`, msgID: "m-3", status: protocol.ERROR, reason: protocol.MALFORMED_PAYLOAD},
+		{name: "missing CMD", payload: `{"msg_id": "m-4"}`, msgID: "m-4", status: protocol.ERROR, reason: protocol.MALFORMED_PAYLOAD},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			tr := transport.NewMemory(100)
+			dedupStore = dedup.NewStore(tr, time.Minute, 10)
+			sub, err := tr.Subscribe(ctx, "MODULE_Q")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer sub.Close()
+
+			recieveCommand(ctx, tr, "CMD_Q", tt.payload, state.Initialize(state.DefaultConfig))
+
+			var final *protocol.Reply
+			for final == nil {
+				select {
+				case msg := <-sub.Messages():
+					r, err := protocol.UnmarshalReply([]byte(msg.Payload))
+					if err == nil && r.Type == protocol.REPLY && r.IsFinal() {
+						final = &r
+					}
+				default:
+					t.Fatal("no final reply published")
+				}
+			}
+			if final.MsgID != tt.msgID {
+				t.Fatalf("reply msg_id %q, want %q", final.MsgID, tt.msgID)
+			}
+			if final.Status != tt.status || final.Reason != tt.reason {
+				t.Fatalf("reply %s %s (%s), want %s %s", final.Status, final.Reason, final.Message, tt.status, tt.reason)
+			}
+			if tt.reason == protocol.MALFORMED_PAYLOAD && final.Data["error"] == nil {
+				t.Fatal("malformed payload reply without the parse error")
+			}
+		})
+	}
+}
+
+// replyRecorder records the status of every reply published on MODULE_Q
+// with the dedup state of its msg_id at that moment
+type replyRecorder struct {
+	transport.Transport
+	got []string // "STATUS/DEDUP_STATE", the state empty without an entry
+}
+
+func (r *replyRecorder) Publish(ctx context.Context, channel, payload string) (int64, error) {
+	if reply, err := protocol.UnmarshalReply([]byte(payload)); channel == "MODULE_Q" && err == nil && reply.Type == protocol.REPLY {
+		entry, _ := dedupStore.Get(ctx, reply.MsgID)
+		status := string(reply.Status) + "/" + entry.State
+		// One PROGRESS stands for all of them
+		if n := len(r.got); n == 0 || r.got[n-1] != status || reply.Status != protocol.PROGRESS {
+			r.got = append(r.got, status)
+		}
+	}
+	return r.Transport.Publish(ctx, channel, payload)
+}
+
+func TestRecieveCommandOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		want    []string
+		done    bool // Outcome kept for duplicates
+	}{
+		{name: "result", payload: `{"msg_id": "m-1", "CMD": "HEALTH_CHECK"}`,
+			want: []string{"ACK/IN_FLIGHT", "ACCEPTED/IN_FLIGHT", "RESULT/IN_FLIGHT"}, done: true},
+		{name: "progress", payload: `{"msg_id": "m-2", "CMD": "PERFORM_MANEUVER", "ARGS": {"x": 1, "y": 0, "z": 0}}`,
+			want: []string{"ACK/IN_FLIGHT", "ACCEPTED/IN_FLIGHT", "PROGRESS/IN_FLIGHT", "RESULT/IN_FLIGHT"}, done: true},
+		{name: "rejected", payload: `{"msg_id": "m-3", "CMD": "PERFORM_MANEUVER", "ARGS": {"x": 900, "y": 0, "z": 0}}`,
+			want: []string{"ACK/IN_FLIGHT", "REJECTED/IN_FLIGHT"}, done: true},
+		{name: "malformed", payload: `{"msg_id": "m-4", "CMD": "HEALTH_CHECK"`,
+			want: []string{"ERROR/"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			tr := &replyRecorder{Transport: transport.NewMemory(100)}
+			dedupStore = dedup.NewStore(tr, time.Minute, 10)
+
+			recieveCommand(ctx, tr, "CMD_Q", tt.payload, state.Initialize(state.DefaultConfig))
+
+			if fmt.Sprint(tr.got) != fmt.Sprint(tt.want) {
+				t.Fatalf("replies %v, want %v", tr.got, tt.want)
+			}
+			var cmd struct {
+				MsgID string `json:"msg_id"`
+			}
+			json.Unmarshal([]byte(tt.payload), &cmd)
+			entry, _ := dedupStore.Get(ctx, cmd.MsgID)
+			if done := entry.State == dedup.DONE; done != tt.done {
+				t.Fatalf("dedup entry %q after the final reply, want DONE %v", entry.State, tt.done)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Message protocol between the host and the module (see Plan.md)
+//
+// Host -> Module:   command.Command on CMD_Q
+// Module -> Host:   Reply on MODULE_Q
+
+type MsgType string
+
+const (
+	COMMAND   MsgType = "COMMAND"
+	HEARTBEAT MsgType = "HEARTBEAT"
+	REPLY     MsgType = "REPLY"
+	EVENT     MsgType = "EVENT"
+)
+
+// Status of a reply to a command
+type Status string
+
+const (
+	ACK      Status = "ACK"      // Command received
+	ACCEPTED Status = "ACCEPTED" // Command passed checks and is running
+	REJECTED Status = "REJECTED" // Command refused, see Reason
+	PROGRESS Status = "PROGRESS" // Intermediate update of a running command
+	RESULT   Status = "RESULT"   // Final outcome of a command
+	ERROR    Status = "ERROR"    // Command failed, see Reason
+)
+
+// Reason is a machine readable code set on REJECTED and ERROR replies.
+// The host should match on these and never on the human readable Message.
+type Reason string
+
+const (
+	MODULE_NOT_IDLE   Reason = "MODULE_NOT_IDLE"
+	UNSAFE_CONDITIONS Reason = "UNSAFE_CONDITIONS"
+	PRECHECK_FAILED   Reason = "PRECHECK_FAILED"
+	HEARTBEAT_LOST    Reason = "HEARTBEAT_LOST"
+	INVALID_ARGS      Reason = "INVALID_ARGS"
+	THRUST_INHIBITED  Reason = "THRUST_INHIBITED" // Maneuver refused, inhibit asserted
+	INHIBIT_ABORT     Reason = "INHIBIT_ABORT"    // Inhibit asserted mid maneuver, module SAFE
+	MODULE_SAFE       Reason = "MODULE_SAFE"      // Command not allowed while latched in SAFE
+	BUSY              Reason = "BUSY"             // Another exclusive command is running
+	INVALID_STATE     Reason = "INVALID_STATE"    // Command not allowed in the current state
+	ABORTED           Reason = "ABORTED"          // Command cancelled by ABORT / ABORT_ALL
+	TIMEOUT           Reason = "TIMEOUT"          // Command ran out of time
+	NOT_IN_FLIGHT     Reason = "NOT_IN_FLIGHT"    // ABORT of a msg_id that is not running
+
+	INSUFFICIENT_PROPELLANT Reason = "INSUFFICIENT_PROPELLANT" // Maneuver needs more propellant than left
+	INSUFFICIENT_POWER      Reason = "INSUFFICIENT_POWER"      // Maneuver would drain the battery below the SAFE limit
+	OVERTEMP                Reason = "OVERTEMP"                // Temperature crossed the thermal limit, module SAFE
+	BROWNOUT                Reason = "BROWNOUT"                // Battery voltage too low to maneuver
+	BATTERY_CRITICAL        Reason = "BATTERY_CRITICAL"        // Battery voltage critical, module SAFE
+	HISTORY_UNAVAILABLE     Reason = "HISTORY_UNAVAILABLE"     // History stream cannot be read
+	ALREADY_IN_FLIGHT       Reason = "ALREADY_IN_FLIGHT"       // A command with the same msg_id is running
+
+	UNRECOGNIZED_COMMAND Reason = "UNRECOGNIZED_COMMAND"
+	MALFORMED_PAYLOAD    Reason = "MALFORMED_PAYLOAD"
+)
+
+// Reply sent by the module. MsgID echoes the msg_id of the host command.
+type Reply struct {
+	MsgID       string                 `json:"msg_id"`
+	Type        MsgType                `json:"type"`
+	Cmd         string                 `json:"cmd,omitempty"`
+	Status      Status                 `json:"status"`
+	Reason      Reason                 `json:"reason,omitempty"`
+	Message     string                 `json:"message,omitempty"`
+	Data        map[string]interface{} `json:"data,omitempty"`
+	Dup         bool                   `json:"dup"`
+	SystemState map[string]interface{} `json:"system_state,omitempty"`
+	MsgTime     string                 `json:"msg_time"`
+	Ephemeral   bool                   `json:"-"` // Not kept in the module history
+}
+
+// NewReply creates a reply to the host command msgID with the given status
+func NewReply(msgID, cmd string, status Status, message string) Reply {
+	return Reply{
+		MsgID:   msgID,
+		Type:    REPLY,
+		Cmd:     cmd,
+		Status:  status,
+		Message: message,
+		Data:    map[string]interface{}{},
+	}
+}
+
+// Reject creates a REJECTED reply for cmd
+func Reject(msgID, cmd string, reason Reason, message string) Reply {
+	r := NewReply(msgID, cmd, REJECTED, message)
+	r.Reason = reason
+	return r
+}
+
+// Fail creates an ERROR reply for cmd
+func Fail(msgID, cmd string, reason Reason, message string) Reply {
+	r := NewReply(msgID, cmd, ERROR, message)
+	r.Reason = reason
+	return r
+}
+
+// IsFinal reports whether no further replies will follow for the command
+func (r Reply) IsFinal() bool {
+	switch r.Status {
+	case REJECTED, RESULT, ERROR:
+		return true
+	default:
+		return false
+	}
+}
+
+// Event is published by the module on its own (STATUS, WARNING, FAULT).
+// It carries a freshly generated MsgID, CorrelationID points at the host
+// command that was in effect when the event was raised (if any).
+type Event struct {
+	MsgID         string                 `json:"msg_id"`
+	Type          MsgType                `json:"type"`
+	Message       string                 `json:"message"`
+	CorrelationID string                 `json:"correlation_id,omitempty"`
+	Reason        Reason                 `json:"reason,omitempty"`
+	Data          map[string]interface{} `json:"data,omitempty"`
+	SystemState   map[string]interface{} `json:"system_state,omitempty"`
+	MsgTime       string                 `json:"msg_time"`
+}
+
+// NewEvent creates an event with a new unique msg_id
+func NewEvent(message, correlationID string) Event {
+	return Event{
+		MsgID:         uuid.New().String(),
+		Type:          EVENT,
+		Message:       message,
+		CorrelationID: correlationID,
+		Data:          map[string]interface{}{},
+	}
+}
+
+// Stamp sets the type and message time of the event if unset
+func (e Event) Stamp() Event {
+	if e.Type == "" {
+		e.Type = EVENT
+	}
+	if e.MsgTime == "" {
+		e.MsgTime = time.Now().Format(time.RFC3339)
+	}
+	return e
+}
+
+// MarshalEvent stamps the message time (if unset) and encodes the event
+func MarshalEvent(e Event) ([]byte, error) {
+	data, err := json.Marshal(e.Stamp())
+	if err != nil {
+		return nil, fmt.Errorf("marshal event: %w", err)
+	}
+	return data, nil
+}
+
+// Stamp sets the type and message time of the reply if unset
+func (r Reply) Stamp() Reply {
+	if r.Type == "" {
+		r.Type = REPLY
+	}
+	if r.MsgTime == "" {
+		r.MsgTime = time.Now().Format(time.RFC3339)
+	}
+	return r
+}
+
+// MarshalReply stamps the message time (if unset) and encodes the reply
+func MarshalReply(r Reply) ([]byte, error) {
+	data, err := json.Marshal(r.Stamp())
+	if err != nil {
+		return nil, fmt.Errorf("marshal reply: %w", err)
+	}
+	return data, nil
+}
+
+func UnmarshalReply(data []byte) (Reply, error) {
+	var r Reply
+	if err := json.Unmarshal(data, &r); err != nil {
+		return Reply{}, fmt.Errorf("unmarshal reply: %w", err)
+	}
+	return r, nil
+}
+
+func UnmarshalEvent(data []byte) (Event, error) {
+	var e Event
+	if err := json.Unmarshal(data, &e); err != nil {
+		return Event{}, fmt.Errorf("unmarshal event: %w", err)
+	}
+	return e, nil
+}
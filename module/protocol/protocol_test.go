@@ -0,0 +1,167 @@
+package protocol
+
+import (
+	"communication_module/command"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestReplyRoundTrip(t *testing.T) {
+	full := Reject("m-1", "PERFORM_MANEUVER", INVALID_ARGS, "x out of range")
+	full.Data["field"] = "x"
+	full.Data["limit"] = 200.0
+	full.Dup = true
+	full.SystemState = map[string]interface{}{"Status": "IDLE"}
+
+	ephemeral := NewReply("m-1", "HEALTH_CHECK", PROGRESS, "")
+	ephemeral.Ephemeral = true
+
+	tests := []struct {
+		name  string
+		reply Reply
+		want  Reply // reply itself when zero
+	}{
+		{name: "full", reply: full},
+		{name: "unknown status", reply: NewReply("m-1", "HEALTH_CHECK", Status("LATER"), "")},
+		{name: "missing msg_id", reply: NewReply("", "HEALTH_CHECK", ACK, "")},
+		{name: "not on the wire", reply: ephemeral, want: NewReply("m-1", "HEALTH_CHECK", PROGRESS, "")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := MarshalReply(tt.reply)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := UnmarshalReply(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := tt.want
+			if want.Type == "" {
+				want = tt.reply
+			}
+			want.MsgTime = got.MsgTime
+			if len(want.Data) == 0 {
+				// Empty data is left out
+				want.Data = nil
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("round trip %+v, want %+v", got, want)
+			}
+			if got.MsgTime == "" {
+				t.Fatal("reply not stamped")
+			}
+		})
+	}
+}
+
+func TestUnmarshalReply(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		msgID   string
+		status  Status
+		final   bool
+		err     bool
+	}{
+		{name: "result", payload: `{"msg_id": "m-1", "type": "REPLY", "status": "RESULT"}`, msgID: "m-1", status: RESULT, final: true},
+		{name: "unknown status", payload: `{"msg_id": "m-1", "type": "REPLY", "status": "LATER"}`, msgID: "m-1", status: "LATER"},
+		{name: "missing msg_id", payload: `{"type": "REPLY", "status": "ERROR"}`, status: ERROR, final: true},
+		{name: "not json", payload: `{"msg_id": "m-1"`, err: true},
+		{name: "wrong type", payload: `{"msg_id": 1, "status": "ACK"}`, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := UnmarshalReply([]byte(tt.payload))
+			if (err != nil) != tt.err {
+				t.Fatalf("err %v, want error %v", err, tt.err)
+			}
+			if got.MsgID != tt.msgID || got.Status != tt.status || got.IsFinal() != tt.final {
+				t.Fatalf("reply %q %s final %v, want %q %s final %v", got.MsgID, got.Status, got.IsFinal(), tt.msgID, tt.status, tt.final)
+			}
+		})
+	}
+}
+
+func TestEventRoundTrip(t *testing.T) {
+	fault := NewEvent("FAULT", "m-1")
+	fault.Reason = HEARTBEAT_LOST
+	fault.Data["missed_beats"] = 3.0
+
+	tests := []struct {
+		name  string
+		event Event
+	}{
+		{name: "correlated", event: fault},
+		{name: "module raised", event: NewEvent("STATUS", "")},
+		{name: "unknown message", event: NewEvent("SOMETHING_NEW", "")},
+		{name: "missing msg_id", event: Event{Message: "STATUS"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := MarshalEvent(tt.event)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := UnmarshalEvent(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := tt.event.Stamp()
+			want.MsgTime = got.MsgTime
+			if len(want.Data) == 0 {
+				want.Data = nil
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("round trip %+v, want %+v", got, want)
+			}
+			if got.Type != EVENT {
+				t.Fatalf("type %q, want EVENT", got.Type)
+			}
+		})
+	}
+	if _, err := UnmarshalEvent([]byte(`{"msg_id": "e-1"`)); err == nil {
+		t.Fatal("UnmarshalEvent of a truncated payload")
+	}
+}
+
+func TestCommandRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		cmd   command.Command
+		msgID string // After parsing, "*" for a generated one
+		err   bool
+	}{
+		{name: "with args", cmd: command.Command{MSG_ID: "m-1", CMD: "PERFORM_MANEUVER", CMD_COUNTER: 7, ARGS: json.RawMessage(`{"x":1,"y":0,"z":0}`)}, msgID: "m-1"},
+		{name: "unknown command", cmd: command.Command{MSG_ID: "m-1", CMD: "WARP_DRIVE"}, msgID: "m-1"},
+		{name: "missing msg_id", cmd: command.Command{CMD: "HEALTH_CHECK", CMD_HASH: "h-1"}, msgID: "h-1"},
+		{name: "missing msg_id and hash", cmd: command.Command{CMD: "HEALTH_CHECK"}, msgID: "*"},
+		{name: "missing CMD", cmd: command.Command{MSG_ID: "m-1"}, msgID: "m-1", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.cmd)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := command.ParseCommand(string(data))
+			if (err != nil) != tt.err {
+				t.Fatalf("err %v, want error %v", err, tt.err)
+			}
+			if tt.msgID == "*" {
+				if got.MSG_ID == "" {
+					t.Fatal("no msg_id generated")
+				}
+				got.MSG_ID = ""
+			} else if got.MSG_ID != tt.msgID {
+				t.Fatalf("msg_id %q, want %q", got.MSG_ID, tt.msgID)
+			} else {
+				got.MSG_ID = tt.cmd.MSG_ID
+			}
+			if !reflect.DeepEqual(got, tt.cmd) {
+				t.Fatalf("round trip %+v, want %+v", got, tt.cmd)
+			}
+		})
+	}
+}
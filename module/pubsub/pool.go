@@ -0,0 +1,54 @@
+package pubsub
+
+import (
+	"communication_module/state"
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// pool runs workers goroutines calling handle with the items of in. A
+// handler whose command queues for the exclusive slot (state.WithQueued)
+// hands its place in the pool to a new worker and exits once it returns,
+// so queued commands never hold up the control commands and ACKs behind
+// them. The executor refuses commands past state.MaxQueued, which bounds
+// the extra workers.
+func pool[T any](ctx context.Context, workers int, in <-chan T, handle func(ctx context.Context, id int, item T)) *sync.WaitGroup {
+	wg := &sync.WaitGroup{}
+	var ids atomic.Int64
+
+	var worker func(id int)
+	worker = func(id int) {
+		defer wg.Done()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case item, ok := <-in:
+				if !ok {
+					return
+				}
+				replaced := false
+				callCtx := state.WithQueued(ctx, func() {
+					if !replaced {
+						replaced = true
+						start(wg, &ids, worker)
+					}
+				})
+				handle(callCtx, id, item)
+				if replaced {
+					return
+				}
+			}
+		}
+	}
+	for i := 0; i < workers; i++ {
+		start(wg, &ids, worker)
+	}
+	return wg
+}
+
+func start(wg *sync.WaitGroup, ids *atomic.Int64, worker func(id int)) {
+	wg.Add(1)
+	go worker(int(ids.Add(1)))
+}
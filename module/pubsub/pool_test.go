@@ -0,0 +1,88 @@
+package pubsub
+
+import (
+	"communication_module/state"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPoolQueuedDoesNotBlock(t *testing.T) {
+	tests := []struct {
+		name    string
+		workers int
+		queued  int // Commands queued for the busy slot before the control command
+		refused int // Of those, refused as BUSY past state.MaxQueued
+	}{
+		{name: "one worker", workers: 1, queued: 1},
+		{name: "all workers queued", workers: 4, queued: 4},
+		{name: "queue full", workers: 4, queued: state.MaxQueued + 2, refused: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			executor := state.NewExecutor()
+			release, err := executor.Acquire(ctx, "running", state.REJECT_WHEN_BUSY)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			in := make(chan string)
+			handled := make(chan string, tt.queued+1)
+			wg := pool(ctx, tt.workers, in, func(ctx context.Context, id int, item string) {
+				if item == "queued" {
+					release, err := executor.Acquire(ctx, item, state.QUEUE_WHEN_BUSY)
+					if errors.Is(err, state.ErrBusy) {
+						handled <- "refused"
+						return
+					}
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					release()
+				}
+				handled <- item
+			})
+
+			for i := 0; i < tt.queued; i++ {
+				in <- "queued"
+			}
+			select {
+			case in <- "control":
+			case <-time.After(time.Second):
+				t.Fatal("pool blocked by queued commands")
+			}
+			// Refused commands may finish before or after control, the
+			// queued ones only once the slot is released
+			refused := 0
+			for control := false; !control || refused < tt.refused; {
+				select {
+				case item := <-handled:
+					switch item {
+					case "control":
+						control = true
+					case "refused":
+						refused++
+					default:
+						t.Fatalf("handled %s before the slot was released", item)
+					}
+				case <-time.After(time.Second):
+					t.Fatalf("%d commands refused, want %d", refused, tt.refused)
+				}
+			}
+
+			release()
+			for i := 0; i < tt.queued-tt.refused; i++ {
+				if item := <-handled; item != "queued" {
+					t.Fatalf("handled %s, want queued", item)
+				}
+			}
+			cancel()
+			wg.Wait()
+		})
+	}
+}
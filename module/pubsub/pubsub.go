@@ -2,60 +2,38 @@ package pubsub
 
 import (
 	"communication_module/state"
+	"communication_module/transport"
 	"context"
 	"log"
 	"runtime"
-	"sync"
-	"time"
-
-	"github.com/redis/go-redis/v9"
 )
 
 // Handler is a callback for processing each Pub/Sub message.
-type Handler func(ctx context.Context, rdb *redis.Client, channel, payload string, ms *state.ModuleState) error
+type Handler func(ctx context.Context, tr transport.Transport, channel, payload string, ms *state.ModuleState) error
 
-// SubscribeAsync subscribes to Redis channels and dispatches messages to a worker pool.
-func SubscribeAsync(ctx context.Context, rdb *redis.Client, channels []string, workers, buf int, ms *state.ModuleState, h Handler) (stop func(), err error) {
+// SubscribeAsync subscribes to channels of the transport and dispatches messages to a worker pool.
+// Messages are buffered by the transport subscription.
+func SubscribeAsync(ctx context.Context, tr transport.Transport, channels []string, workers int, ms *state.ModuleState, h Handler) (stop func(), err error) {
 	if workers <= 0 {
 		workers = runtime.NumCPU()
 	}
-	if buf <= 0 {
-		buf = 1024
-	}
 
-	ps := rdb.Subscribe(ctx, channels...)
-	if _, err := ps.Receive(ctx); err != nil {
+	ps, err := tr.Subscribe(ctx, channels...)
+	if err != nil {
 		return nil, err
 	}
 
-	msgCh := ps.Channel(redis.WithChannelSize(buf))
-
-	wg := &sync.WaitGroup{}
-	wg.Add(workers)
+	msgCh := ps.Messages()
 
 	workerCtx, cancel := context.WithCancel(ctx)
 
-	for i := 0; i < workers; i++ {
-		go func(id int) {
-			defer wg.Done()
-			for {
-				select {
-				case <-workerCtx.Done():
-					return
-				case m, ok := <-msgCh:
-					if !ok {
-						return
-					}
-					callCtx, cancel := context.WithTimeout(workerCtx, 30*time.Second)
-					if err := h(callCtx, rdb, m.Channel, m.Payload, ms); err != nil {
-						log.Printf("[worker %d] handler error: %v (channel=%s)", id, err, m.Channel)
-					}
-
-					cancel()
-				}
-			}
-		}(i + 1)
-	}
+	wg := pool(workerCtx, workers, msgCh, func(ctx context.Context, id int, m transport.Message) {
+		callCtx, cancel := context.WithTimeout(ctx, state.HandlerTimeout())
+		defer cancel()
+		if err := h(callCtx, tr, m.Channel, m.Payload, ms); err != nil {
+			log.Printf("[worker %d] handler error: %v (channel=%s)", id, err, m.Channel)
+		}
+	})
 
 	done := make(chan struct{})
 	go func() {
@@ -0,0 +1,316 @@
+package pubsub
+
+import (
+	"communication_module/logger"
+	"communication_module/protocol"
+	"communication_module/state"
+	"communication_module/transport"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Command intake on a Redis Stream. Unlike Pub/Sub, commands added while
+// the module is down wait in the stream. Every entry is read through a
+// consumer group and acked once the handler returns nil (after the final
+// reply). Entries that stay pending, because the handler failed or the
+// module died, are reclaimed with XAUTOCLAIM and run again. After
+// MaxDeliveries they are moved to the dead-letter stream instead.
+
+// PAYLOAD_FIELD is the stream entry field holding the command JSON
+const PAYLOAD_FIELD = "payload"
+
+// ErrUndeliverable from a handler dead-letters the entry straight away,
+// for commands that can never succeed (e.g. a malformed payload)
+var ErrUndeliverable = errors.New("undeliverable command")
+
+// StreamConfig of the stream intake
+type StreamConfig struct {
+	Stream         string        // Command stream the host XADDs to
+	Group          string        // Consumer group of the module
+	Consumer       string        // Consumer name of this module
+	DeadLetter     string        // Stream for commands that keep failing
+	MaxDeliveries  int64         // Deliveries before an entry is dead-lettered
+	MinIdle        time.Duration // Pending this long (without ack) is reclaimed, must exceed HandlerTimeout
+	HandlerTimeout time.Duration // Longest a command may run
+	ReclaimEvery   time.Duration
+	Block          time.Duration // XREADGROUP wait, also the stop latency
+	Batch          int64         // Entries per read
+}
+
+var DefaultStream = StreamConfig{
+	Stream:         "CMD_STREAM",
+	Group:          "module",
+	Consumer:       "module-1",
+	DeadLetter:     "CMD_DEAD_LETTER",
+	MaxDeliveries:  5,
+	MinIdle:        90 * time.Second,
+	HandlerTimeout: state.HandlerTimeout(),
+	ReclaimEvery:   5 * time.Second,
+	Block:          2 * time.Second,
+	Batch:          10,
+}
+
+// StreamConfigFromEnv overrides cfg with MODULE_STREAM_CONSUMER,
+// MODULE_STREAM_MIN_IDLE (Go duration) and MODULE_STREAM_MAX_DELIVERIES
+func StreamConfigFromEnv(cfg StreamConfig) (StreamConfig, error) {
+	if v := os.Getenv("MODULE_STREAM_CONSUMER"); v != "" {
+		cfg.Consumer = v
+	}
+	if v := os.Getenv("MODULE_STREAM_MIN_IDLE"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return cfg, fmt.Errorf("MODULE_STREAM_MIN_IDLE: invalid duration %q", v)
+		}
+		cfg.MinIdle = d
+	}
+	if v := os.Getenv("MODULE_STREAM_MAX_DELIVERIES"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			return cfg, fmt.Errorf("MODULE_STREAM_MAX_DELIVERIES: invalid count %q", v)
+		}
+		cfg.MaxDeliveries = n
+	}
+	return cfg, nil
+}
+
+// streamIntake is the state shared by the reader, reclaimer and workers
+type streamIntake struct {
+	tr      transport.Transport
+	streams transport.Streams
+	cfg     StreamConfig
+	ms      *state.ModuleState
+
+	mu      sync.Mutex
+	running map[string]bool // Entry IDs a worker of this module is on
+}
+
+// ConsumeStream reads commands from cfg.Stream and dispatches them to a
+// worker pool. The transport must implement transport.Streams.
+func ConsumeStream(ctx context.Context, tr transport.Transport, cfg StreamConfig, workers int, ms *state.ModuleState, h Handler) (stop func(), err error) {
+	streams, ok := tr.(transport.Streams)
+	if !ok {
+		return nil, fmt.Errorf("transport %T has no streams", tr)
+	}
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if cfg.HandlerTimeout <= 0 {
+		cfg.HandlerTimeout = DefaultStream.HandlerTimeout
+	}
+	if cfg.MinIdle <= cfg.HandlerTimeout {
+		// A command still running would be reclaimed and run twice
+		return nil, fmt.Errorf("min idle %v of %s must exceed the handler timeout %v", cfg.MinIdle, cfg.Stream, cfg.HandlerTimeout)
+	}
+	// "0" so commands added before the group existed are not skipped
+	if err := streams.XGroupCreate(ctx, cfg.Stream, cfg.Group, "0"); err != nil {
+		return nil, fmt.Errorf("create group %s on %s: %w", cfg.Group, cfg.Stream, err)
+	}
+
+	in := &streamIntake{tr: tr, streams: streams, cfg: cfg, ms: ms, running: map[string]bool{}}
+	workerCtx, cancel := context.WithCancel(ctx)
+	entryCh := make(chan transport.StreamEntry)
+	wg := pool(workerCtx, workers, entryCh, func(ctx context.Context, id int, e transport.StreamEntry) {
+		in.handle(ctx, id, e, h)
+	})
+
+	feeders := &sync.WaitGroup{}
+	feeders.Add(2)
+	go func() {
+		defer feeders.Done()
+		in.read(workerCtx, entryCh)
+	}()
+	go func() {
+		defer feeders.Done()
+		in.reclaim(workerCtx, entryCh)
+	}()
+
+	stop = func() {
+		cancel()
+		feeders.Wait()
+		close(entryCh)
+		wg.Wait()
+	}
+	return stop, nil
+}
+
+// read feeds new entries of the stream to the workers
+func (in *streamIntake) read(ctx context.Context, entryCh chan<- transport.StreamEntry) {
+	for ctx.Err() == nil {
+		entries, err := in.streams.XReadGroup(ctx, in.cfg.Stream, in.cfg.Group, in.cfg.Consumer, in.cfg.Batch, in.cfg.Block)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("[streams] read %s: %v", in.cfg.Stream, err)
+				sleep(ctx, in.cfg.Block)
+			}
+			continue
+		}
+		for _, e := range entries {
+			if !in.dispatch(ctx, entryCh, e) {
+				return
+			}
+		}
+	}
+}
+
+// reclaim periodically dead-letters entries that keep failing and takes
+// over the other entries pending for longer than MinIdle
+func (in *streamIntake) reclaim(ctx context.Context, entryCh chan<- transport.StreamEntry) {
+	ticker := time.NewTicker(in.cfg.ReclaimEvery)
+	defer ticker.Stop()
+	for {
+		exhausted := in.deadLetterExhausted(ctx)
+		claimed, err := in.streams.XAutoClaim(ctx, in.cfg.Stream, in.cfg.Group, in.cfg.Consumer, in.cfg.MinIdle, in.cfg.Batch)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("[streams] autoclaim %s: %v", in.cfg.Stream, err)
+		}
+		for _, e := range claimed {
+			if in.isRunning(e.ID) {
+				// Still being worked on here, only slow
+				continue
+			}
+			if exhausted[e.ID] {
+				// Went idle just after the dead-letter check, dead-lettered
+				// next round instead of running once more
+				continue
+			}
+			logger.Warning("Reclaimed command ", e.ID, " from ", in.cfg.Stream)
+			if !in.dispatch(ctx, entryCh, e) {
+				return
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// dispatch hands e to a worker, false once the intake stops
+func (in *streamIntake) dispatch(ctx context.Context, entryCh chan<- transport.StreamEntry, e transport.StreamEntry) bool {
+	in.mu.Lock()
+	in.running[e.ID] = true
+	in.mu.Unlock()
+	select {
+	case entryCh <- e:
+		return true
+	case <-ctx.Done():
+		in.done(e.ID)
+		return false
+	}
+}
+
+func (in *streamIntake) isRunning(id string) bool {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return in.running[id]
+}
+
+func (in *streamIntake) done(id string) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	delete(in.running, id)
+}
+
+// handle runs the handler on one entry and acks or dead-letters it
+func (in *streamIntake) handle(ctx context.Context, worker int, e transport.StreamEntry, h Handler) {
+	defer in.done(e.ID)
+	payload, ok := e.Values[PAYLOAD_FIELD]
+	if !ok {
+		in.deadLetter(ctx, e, 1, fmt.Sprintf("no %q field", PAYLOAD_FIELD))
+		return
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, in.cfg.HandlerTimeout)
+	err := h(callCtx, in.tr, in.cfg.Stream, payload, in.ms)
+	cancel()
+
+	switch {
+	case err == nil:
+		if err := in.streams.XAck(context.Background(), in.cfg.Stream, in.cfg.Group, e.ID); err != nil {
+			log.Printf("[worker %d] ack %s: %v", worker, e.ID, err)
+		}
+	case errors.Is(err, ErrUndeliverable):
+		in.deadLetter(ctx, e, 1, err.Error())
+	default:
+		// Stays pending, reclaimed after MinIdle
+		log.Printf("[worker %d] handler error: %v (entry=%s, stays pending)", worker, err, e.ID)
+	}
+}
+
+// deadLetterExhausted moves idle entries delivered MaxDeliveries times to
+// the dead-letter stream. It returns the exhausted entries it left pending
+// (not idle yet, or running), which must not be run again.
+func (in *streamIntake) deadLetterExhausted(ctx context.Context) map[string]bool {
+	exhausted := map[string]bool{}
+	pending, err := in.streams.XPending(ctx, in.cfg.Stream, in.cfg.Group, 100)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("[streams] pending %s: %v", in.cfg.Stream, err)
+		}
+		return exhausted
+	}
+	for _, p := range pending {
+		if p.Deliveries < in.cfg.MaxDeliveries {
+			continue
+		}
+		if p.Idle < in.cfg.MinIdle || in.isRunning(p.ID) {
+			exhausted[p.ID] = true
+			continue
+		}
+		entries, err := in.streams.XRange(ctx, in.cfg.Stream, p.ID, p.ID, 1)
+		if err != nil {
+			log.Printf("[streams] read %s: %v", p.ID, err)
+			continue
+		}
+		e := transport.StreamEntry{ID: p.ID}
+		if len(entries) == 1 {
+			e = entries[0]
+		}
+		in.deadLetter(ctx, e, p.Deliveries, fmt.Sprintf("delivered %d times without success", p.Deliveries))
+	}
+	return exhausted
+}
+
+// deadLetter copies e to the dead-letter stream, acks it and tells the host
+func (in *streamIntake) deadLetter(ctx context.Context, e transport.StreamEntry, deliveries int64, why string) {
+	values := map[string]string{
+		"id":         e.ID,
+		"stream":     in.cfg.Stream,
+		"deliveries": strconv.FormatInt(deliveries, 10),
+		"error":      why,
+	}
+	if payload, ok := e.Values[PAYLOAD_FIELD]; ok {
+		values[PAYLOAD_FIELD] = payload
+	}
+	if _, err := in.streams.XAdd(ctx, in.cfg.DeadLetter, 0, values); err != nil {
+		log.Printf("[streams] dead-letter %s: %v (left pending)", e.ID, err)
+		return
+	}
+	if err := in.streams.XAck(ctx, in.cfg.Stream, in.cfg.Group, e.ID); err != nil {
+		log.Printf("[streams] ack %s: %v", e.ID, err)
+	}
+	logger.Error("Dead-lettered command ", e.ID, ": ", why)
+
+	event := protocol.NewEvent("DEAD_LETTER", "")
+	event.Data["id"] = e.ID
+	event.Data["dead_letter"] = in.cfg.DeadLetter
+	event.Data["deliveries"] = deliveries
+	event.Data["error"] = why
+	logger.PubEvent(ctx, in.tr, event, in.ms.Snapshot(), "MODULE_Q")
+}
+
+func sleep(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
@@ -0,0 +1,136 @@
+package pubsub
+
+import (
+	"communication_module/state"
+	"communication_module/transport"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConsumeStream(t *testing.T) {
+	cfg := StreamConfig{
+		Stream:         "CMD_STREAM",
+		Group:          "module",
+		Consumer:       "module-1",
+		DeadLetter:     "CMD_DEAD_LETTER",
+		MaxDeliveries:  3,
+		MinIdle:        60 * time.Millisecond,
+		HandlerTimeout: 20 * time.Millisecond,
+		ReclaimEvery:   10 * time.Millisecond,
+		Block:          10 * time.Millisecond,
+		Batch:          10,
+	}
+
+	tests := []struct {
+		name   string
+		values map[string]string
+		calls  int // Handler runs
+		dead   bool
+	}{
+		{name: "ok", values: map[string]string{PAYLOAD_FIELD: "ok"}, calls: 1},
+		{name: "failed once, reclaimed", values: map[string]string{PAYLOAD_FIELD: "flaky"}, calls: 2},
+		{name: "undeliverable", values: map[string]string{PAYLOAD_FIELD: "undeliverable"}, calls: 1, dead: true},
+		{name: "always failing", values: map[string]string{PAYLOAD_FIELD: "fail"}, calls: 3, dead: true},
+		{name: "no payload", values: map[string]string{"other": "x"}, calls: 0, dead: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			tr := transport.NewMemory(10)
+			var streams transport.Streams = tr
+			// Added before the intake starts, like a command sent while the module was down
+			id, err := streams.XAdd(ctx, cfg.Stream, 0, tt.values)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var mu sync.Mutex
+			calls := 0
+			h := func(ctx context.Context, tr transport.Transport, channel, payload string, ms *state.ModuleState) error {
+				mu.Lock()
+				defer mu.Unlock()
+				calls++
+				switch {
+				case payload == "undeliverable":
+					return ErrUndeliverable
+				case payload == "fail", payload == "flaky" && calls == 1:
+					return errors.New("failed")
+				}
+				return nil
+			}
+			stop, err := ConsumeStream(ctx, tr, cfg, 2, state.Initialize(state.DefaultConfig), h)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Settled once the handler ran, nothing is pending and the
+			// entry is dead-lettered if it should be
+			settled := func() ([]transport.StreamEntry, bool) {
+				pending, err := streams.XPending(ctx, cfg.Stream, cfg.Group, 10)
+				if err != nil {
+					t.Fatal(err)
+				}
+				dead, err := streams.XRange(ctx, cfg.DeadLetter, "-", "+", 0)
+				if err != nil {
+					t.Fatal(err)
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				return dead, calls >= tt.calls && len(pending) == 0 && (len(dead) > 0) == tt.dead
+			}
+			deadline := time.Now().Add(3 * time.Second)
+			for _, ok := settled(); !ok; _, ok = settled() {
+				if time.Now().After(deadline) {
+					mu.Lock()
+					defer mu.Unlock()
+					t.Fatalf("not settled, handler ran %d times", calls)
+				}
+				time.Sleep(5 * time.Millisecond)
+			}
+			// Long enough for a wrong reclaim to run it again
+			time.Sleep(2 * cfg.MinIdle)
+			stop()
+			dead, _ := settled()
+
+			if calls != tt.calls {
+				t.Fatalf("handler ran %d times, want %d", calls, tt.calls)
+			}
+			if got := len(dead) == 1; got != tt.dead || len(dead) > 1 {
+				t.Fatalf("dead-lettered %+v, want %v", dead, tt.dead)
+			}
+			if tt.dead {
+				if dead[0].Values["id"] != id || dead[0].Values[PAYLOAD_FIELD] != tt.values[PAYLOAD_FIELD] {
+					t.Fatalf("dead letter %+v of %s %+v", dead[0].Values, id, tt.values)
+				}
+			}
+		})
+	}
+}
+
+func TestConsumeStreamMinIdle(t *testing.T) {
+	tests := []struct {
+		name    string
+		minIdle time.Duration
+		ok      bool
+	}{
+		{name: "above handler timeout", minIdle: 90 * time.Second, ok: true},
+		{name: "equal to handler timeout", minIdle: 30 * time.Second, ok: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultStream
+			cfg.MinIdle = tt.minIdle
+			h := func(context.Context, transport.Transport, string, string, *state.ModuleState) error { return nil }
+			stop, err := ConsumeStream(context.Background(), transport.NewMemory(10), cfg, 1, state.Initialize(state.DefaultConfig), h)
+			if (err == nil) != tt.ok {
+				t.Fatalf("err %v, want ok %v", err, tt.ok)
+			}
+			if stop != nil {
+				stop()
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package sim
+
+import (
+	"communication_module/command"
+	"math"
+	"time"
+)
+
+// Maneuver model
+//
+// PERFORM_MANEUVER commands a delta-v per body axis. Each axis has its own
+// thruster pair, the axes fire together and each stops once its commanded
+// delta-v should have been reached at nominal thrust. Real thrusters are
+// not nominal (Efficiency), so the achieved delta-v differs slightly from
+// the commanded one, which is what guidance code on the host has to handle.
+
+const G0 = 9.80665 // Standard gravity, m/s^2
+
+type ManeuverModel struct {
+	DryMassKg  float64        // Module mass without propellant
+	IspS       float64        // Specific impulse of the thrusters
+	ThrustN    command.Vector // Nominal thrust per axis
+	Efficiency command.Vector // Actual / nominal thrust per axis
+	ValveW     float64        // Electrical power per firing axis (valves, catbed heaters)
+	HeatCPerNs float64        // Temperature rise per N·s of impulse
+}
+
+var DefaultManeuver = ManeuverModel{
+	DryMassKg:  50.0,
+	IspS:       220.0,
+	ThrustN:    command.Vector{X: 20, Y: 20, Z: 20},
+	Efficiency: command.Vector{X: 0.99, Y: 1.01, Z: 0.985},
+	ValveW:     10.0,
+	HeatCPerNs: 0.05,
+}
+
+// Plan is the predicted cost of a maneuver
+type Plan struct {
+	Commanded    command.Vector `json:"commanded_mps"` // Delta-v, m/s
+	BurnTime     command.Vector `json:"burn_time_s"`   // Seconds per axis
+	Duration     time.Duration  `json:"duration_ns"`   // Longest axis burn
+	PropellantKg float64        `json:"propellant_kg"`
+	EnergyWh     float64        `json:"energy_wh"`
+	HeatC        float64        `json:"heat_c"`
+}
+
+// Plan predicts the burn of a delta-v (m/s) for the current propellant mass
+func (m ManeuverModel) Plan(dv command.Vector, propellantKg float64) Plan {
+	mass := m.DryMassKg + propellantKg
+	p := Plan{Commanded: dv}
+	p.BurnTime = command.Vector{
+		X: burnTime(mass, dv.X, m.ThrustN.X),
+		Y: burnTime(mass, dv.Y, m.ThrustN.Y),
+		Z: burnTime(mass, dv.Z, m.ThrustN.Z),
+	}
+	longest := math.Max(p.BurnTime.X, math.Max(p.BurnTime.Y, p.BurnTime.Z))
+	p.Duration = time.Duration(longest * float64(time.Second))
+
+	// Rocket equation on the sum of the axes, every axis burns its own propellant
+	total := math.Abs(dv.X) + math.Abs(dv.Y) + math.Abs(dv.Z)
+	p.PropellantKg = mass * (1 - math.Exp(-total/(m.IspS*G0)))
+
+	firing := p.BurnTime.X + p.BurnTime.Y + p.BurnTime.Z
+	p.EnergyWh = firing * m.ValveW / 3600
+	impulse := p.BurnTime.X*m.ThrustN.X + p.BurnTime.Y*m.ThrustN.Y + p.BurnTime.Z*m.ThrustN.Z
+	p.HeatC = impulse * m.HeatCPerNs
+	return p
+}
+
+func burnTime(massKg, dv, thrustN float64) float64 {
+	if thrustN <= 0 {
+		return 0
+	}
+	return massKg * math.Abs(dv) / thrustN
+}
+
+// Burn integrates a planned maneuver step by step
+type Burn struct {
+	model    ManeuverModel
+	plan     Plan
+	elapsed  float64        // Seconds
+	Achieved command.Vector // Delta-v so far, m/s
+}
+
+// Step is what one integration step changed
+type Step struct {
+	DeltaV       command.Vector // m/s
+	PropellantKg float64
+	EnergyWh     float64
+	HeatC        float64
+}
+
+func (m ManeuverModel) Start(plan Plan) *Burn {
+	return &Burn{model: m, plan: plan}
+}
+
+func (b *Burn) Plan() Plan {
+	return b.plan
+}
+
+// Done once every axis burned for its planned time
+func (b *Burn) Done() bool {
+	return b.elapsed >= b.plan.Duration.Seconds()
+}
+
+// Progress of the burn in [0, 1]
+func (b *Burn) Progress() float64 {
+	total := b.plan.Duration.Seconds()
+	if total <= 0 {
+		return 1
+	}
+	return math.Min(b.elapsed/total, 1)
+}
+
+// Step advances the burn by dt seconds at the given module mass. throttle
+// scales the thrust (1 = nominal), a throttled burn achieves less delta-v.
+func (b *Burn) Step(dt, massKg, throttle float64) Step {
+	var s Step
+	axis := func(burnTime, thrustN, efficiency, commanded float64) float64 {
+		// Time this axis still fires within the step
+		t := math.Max(0, math.Min(dt, burnTime-b.elapsed))
+		if t <= 0 {
+			return 0
+		}
+		thrust := thrustN * throttle
+		s.PropellantKg += thrust * t / (b.model.IspS * G0)
+		s.EnergyWh += t * b.model.ValveW / 3600
+		s.HeatC += thrust * t * b.model.HeatCPerNs
+		return math.Copysign(thrust*efficiency*t/massKg, commanded)
+	}
+	s.DeltaV = command.Vector{
+		X: axis(b.plan.BurnTime.X, b.model.ThrustN.X, b.model.Efficiency.X, b.plan.Commanded.X),
+		Y: axis(b.plan.BurnTime.Y, b.model.ThrustN.Y, b.model.Efficiency.Y, b.plan.Commanded.Y),
+		Z: axis(b.plan.BurnTime.Z, b.model.ThrustN.Z, b.model.Efficiency.Z, b.plan.Commanded.Z),
+	}
+	b.elapsed += dt
+	b.Achieved = b.Achieved.Add(s.DeltaV)
+	return s
+}
+
+// Coast advances a position (m) at constant velocity (m/s) for dt seconds
+func Coast(position, velocity command.Vector, dt float64) command.Vector {
+	return position.Add(velocity.Scale(dt))
+}
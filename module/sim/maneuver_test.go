@@ -0,0 +1,125 @@
+package sim
+
+import (
+	"communication_module/command"
+	"math"
+	"testing"
+)
+
+// burn runs plan to completion at a fixed mass and throttle, summing the steps
+func burn(m ManeuverModel, plan Plan, massKg, throttle float64) (*Burn, Step) {
+	b := m.Start(plan)
+	var total Step
+	for !b.Done() {
+		s := b.Step(0.05, massKg, throttle)
+		total.PropellantKg += s.PropellantKg
+		total.EnergyWh += s.EnergyWh
+		total.HeatC += s.HeatC
+	}
+	return b, total
+}
+
+func near(a, b, tol float64) bool {
+	return math.Abs(a-b) <= tol
+}
+
+func TestBurnAchieved(t *testing.T) {
+	dv := command.Vector{X: 1.0, Y: -0.5, Z: 0.25}
+	tests := []struct {
+		name     string
+		throttle float64
+	}{
+		{name: "nominal", throttle: 1},
+		{name: "throttled", throttle: 0.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := DefaultManeuver
+			mass := m.DryMassKg + 5
+			b, _ := burn(m, m.Plan(dv, 5), mass, tt.throttle)
+
+			// Each axis is off nominal by its efficiency, and short by the throttle
+			want := command.Vector{
+				X: dv.X * m.Efficiency.X * tt.throttle,
+				Y: dv.Y * m.Efficiency.Y * tt.throttle,
+				Z: dv.Z * m.Efficiency.Z * tt.throttle,
+			}
+			if !near(b.Achieved.X, want.X, 1e-9) || !near(b.Achieved.Y, want.Y, 1e-9) || !near(b.Achieved.Z, want.Z, 1e-9) {
+				t.Fatalf("achieved %+v, want %+v", b.Achieved, want)
+			}
+			if b.Progress() != 1 {
+				t.Fatalf("progress %v after the burn", b.Progress())
+			}
+		})
+	}
+}
+
+func TestPlanScalesWithBurn(t *testing.T) {
+	m := DefaultManeuver
+	small := m.Plan(command.Vector{X: 0.5}, 5)
+	tests := []struct {
+		name  string
+		scale float64
+	}{
+		{name: "double", scale: 2},
+		{name: "fourfold", scale: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			big := m.Plan(command.Vector{X: 0.5 * tt.scale}, 5)
+			// Burn time, valve energy and heat are linear in delta-v
+			if !near(big.Duration.Seconds(), small.Duration.Seconds()*tt.scale, 1e-6) {
+				t.Fatalf("duration %v, want %v x %v", big.Duration, tt.scale, small.Duration)
+			}
+			if !near(big.EnergyWh, small.EnergyWh*tt.scale, 1e-12) {
+				t.Fatalf("energy %v Wh, want %v x %v", big.EnergyWh, tt.scale, small.EnergyWh)
+			}
+			if !near(big.HeatC, small.HeatC*tt.scale, 1e-9) {
+				t.Fatalf("heat %v°C, want %v x %v", big.HeatC, tt.scale, small.HeatC)
+			}
+			// The rocket equation is slightly less than linear for small burns
+			if big.PropellantKg > small.PropellantKg*tt.scale || big.PropellantKg < small.PropellantKg*tt.scale*0.99 {
+				t.Fatalf("propellant %v kg, want about %v x %v", big.PropellantKg, tt.scale, small.PropellantKg)
+			}
+		})
+	}
+}
+
+func TestBurnMatchesPlan(t *testing.T) {
+	m := DefaultManeuver
+	plan := m.Plan(command.Vector{X: 1.0, Y: 0.5}, 5)
+	_, total := burn(m, plan, m.DryMassKg+5, 1)
+
+	if !near(total.EnergyWh, plan.EnergyWh, 1e-9) {
+		t.Fatalf("burn used %v Wh, plan %v Wh", total.EnergyWh, plan.EnergyWh)
+	}
+	if !near(total.HeatC, plan.HeatC, 1e-9) {
+		t.Fatalf("burn heated %v°C, plan %v°C", total.HeatC, plan.HeatC)
+	}
+	// Constant mass in the steps, the plan uses the rocket equation
+	if !near(total.PropellantKg, plan.PropellantKg, plan.PropellantKg*0.01) {
+		t.Fatalf("burn used %v kg, plan %v kg", total.PropellantKg, plan.PropellantKg)
+	}
+}
+
+func TestPlanPropellantBudget(t *testing.T) {
+	m := DefaultManeuver
+	tests := []struct {
+		name       string
+		propellant float64
+		dv         command.Vector
+		enough     bool
+	}{
+		{name: "small burn", propellant: 5, dv: command.Vector{X: 1}, enough: true},
+		{name: "nearly empty", propellant: 0.01, dv: command.Vector{X: 1}, enough: false},
+		{name: "beyond the tank", propellant: 5, dv: command.Vector{X: 255, Y: 255, Z: 255}, enough: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := m.Plan(tt.dv, tt.propellant)
+			if enough := plan.PropellantKg <= tt.propellant; enough != tt.enough {
+				t.Fatalf("plan needs %v kg of %v kg, enough %v, want %v", plan.PropellantKg, tt.propellant, enough, tt.enough)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package sim
+
+import (
+	"math"
+)
+
+// Power model
+//
+// A battery of CapacityWh is charged by a solar array that is lit for part
+// of every orbit and drained by a constant base load plus whatever the
+// module switched on (heater, camera). Thruster valves are drawn by the
+// maneuver itself (ManeuverModel.ValveW). The terminal voltage follows the
+// state of charge linearly and sags with the load current, below BrownoutV
+// maneuvers are refused and at CriticalV the module is forced SAFE.
+
+type PowerModel struct {
+	CapacityWh     float64 // Usable battery energy
+	FullV          float64 // Open circuit voltage at 100%
+	EmptyV         float64 // Open circuit voltage at 0%
+	InternalOhm    float64 // Internal resistance, voltage sag under load
+	BaseW          float64 // Avionics, radio, always on
+	HeaterW        float64 // Thermostat heater
+	CameraW        float64 // Inspection camera
+	SolarW         float64 // Array output in sunlight
+	OrbitS         float64 // Orbital period
+	SunlitFraction float64 // Part of the orbit in sunlight, the rest is eclipse
+	ChargeEff      float64 // Fraction of the surplus that ends up in the battery
+	BrownoutV      float64 // Maneuvers are refused below this
+	CriticalV      float64 // The module is forced SAFE at or below this
+}
+
+var DefaultPower = PowerModel{
+	CapacityWh:     40.0,
+	FullV:          8.4,
+	EmptyV:         6.0,
+	InternalOhm:    0.1,
+	BaseW:          2.0,
+	HeaterW:        5.0,
+	CameraW:        4.0,
+	SolarW:         8.0,
+	OrbitS:         5400.0,
+	SunlitFraction: 0.6,
+	ChargeEff:      0.95,
+	BrownoutV:      6.72, // ~30%
+	CriticalV:      6.24, // ~10%
+}
+
+// PowerLoads are the consumers switched on by the module
+type PowerLoads struct {
+	Camera bool
+	Heater bool
+}
+
+// Telemetry of the power subsystem after a step
+type PowerTelemetry struct {
+	VoltageV float64 `json:"voltage_v"`
+	CurrentA float64 `json:"current_a"` // Battery current, positive when discharging
+	SolarW   float64 `json:"solar_w"`
+	LoadW    float64 `json:"load_w"`
+}
+
+// Power is a running PowerModel, it keeps the orbit time. It is not safe
+// for concurrent use, the module state calls it with its lock held.
+type Power struct {
+	PowerModel
+	elapsed float64 // Seconds since start, for the orbit phase
+}
+
+func NewPower(m PowerModel) *Power {
+	return &Power{PowerModel: m}
+}
+
+// Sunlit reports whether the array is lit at the current orbit phase
+func (p *Power) Sunlit() bool {
+	if p.OrbitS <= 0 {
+		return true
+	}
+	phase := math.Mod(p.elapsed, p.OrbitS) / p.OrbitS
+	return phase < p.SunlitFraction
+}
+
+// Step advances the battery (percent) by dt seconds
+func (p *Power) Step(pct, dt float64, loads PowerLoads) (float64, PowerTelemetry) {
+	t := PowerTelemetry{LoadW: p.BaseW}
+	if loads.Camera {
+		t.LoadW += p.CameraW
+	}
+	if loads.Heater {
+		t.LoadW += p.HeaterW
+	}
+	if p.Sunlit() {
+		t.SolarW = p.SolarW
+	}
+	p.elapsed += dt
+
+	net := t.LoadW - t.SolarW // Drawn from the battery
+	if net < 0 {
+		net *= p.ChargeEff
+	}
+	before := pct
+	pct = p.Drain(pct, net*dt/3600)
+
+	// Current from the energy that actually moved, a full battery takes no charge
+	ocv := p.OpenCircuitV(pct)
+	if ocv > 0 && dt > 0 {
+		moved := (before - pct) / 100 * p.CapacityWh * 3600 / dt
+		t.CurrentA = moved / ocv
+	}
+	t.VoltageV = p.Voltage(pct, t.CurrentA)
+	return pct, t
+}
+
+// Drain takes energyWh off the battery (negative charges it)
+func (m PowerModel) Drain(pct, energyWh float64) float64 {
+	return math.Max(0, math.Min(100, pct-m.Pct(energyWh)))
+}
+
+// Pct is energyWh as a percentage of the capacity
+func (m PowerModel) Pct(energyWh float64) float64 {
+	if m.CapacityWh <= 0 {
+		return 0
+	}
+	return energyWh / m.CapacityWh * 100
+}
+
+// OpenCircuitV is the unloaded voltage at pct
+func (m PowerModel) OpenCircuitV(pct float64) float64 {
+	return m.EmptyV + (m.FullV-m.EmptyV)*pct/100
+}
+
+// Voltage is the terminal voltage at pct with currentA drawn
+func (m PowerModel) Voltage(pct, currentA float64) float64 {
+	return m.OpenCircuitV(pct) - currentA*m.InternalOhm
+}
+
+// Brownout reports whether voltageV is too low to maneuver
+func (m PowerModel) Brownout(voltageV float64) bool {
+	return voltageV < m.BrownoutV
+}
+
+// Critical reports whether voltageV forces the module SAFE
+func (m PowerModel) Critical(voltageV float64) bool {
+	return voltageV <= m.CriticalV
+}
@@ -0,0 +1,85 @@
+package sim
+
+import (
+	"testing"
+)
+
+func TestPowerStep(t *testing.T) {
+	eclipse := DefaultPower
+	eclipse.SunlitFraction = 0
+	sunlit := DefaultPower
+	sunlit.SunlitFraction = 1
+
+	tests := []struct {
+		name  string
+		model PowerModel
+		start float64
+		dt    float64
+		loads PowerLoads
+		want  float64 // Battery after the step
+	}{
+		{name: "base load in eclipse", model: eclipse, start: 80, dt: 3600, want: 80 - eclipse.Pct(eclipse.BaseW)},
+		{name: "all loads in eclipse", model: eclipse, start: 80, dt: 3600, loads: PowerLoads{Camera: true, Heater: true},
+			want: 80 - eclipse.Pct(eclipse.BaseW+eclipse.CameraW+eclipse.HeaterW)},
+		{name: "charging in sunlight", model: sunlit, start: 50, dt: 3600, want: 50 + sunlit.Pct((sunlit.SolarW-sunlit.BaseW)*sunlit.ChargeEff)},
+		{name: "full battery", model: sunlit, start: 100, dt: 3600, want: 100},
+		{name: "empty battery", model: eclipse, start: 1, dt: 36000, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _ := NewPower(tt.model).Step(tt.start, tt.dt, tt.loads)
+			if !near(got, tt.want, 1e-9) {
+				t.Fatalf("Step(%v%%, %vs) = %v%%, want %v%%", tt.start, tt.dt, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPowerVoltage(t *testing.T) {
+	eclipse := DefaultPower
+	eclipse.SunlitFraction = 0
+	p := NewPower(eclipse)
+
+	pct, tel := p.Step(50, 60, PowerLoads{Camera: true})
+	// Discharging: current from the load, terminal voltage sags below open circuit
+	wantA := (eclipse.BaseW + eclipse.CameraW) / eclipse.OpenCircuitV(pct)
+	if !near(tel.CurrentA, wantA, 1e-6) {
+		t.Fatalf("current %v A, want %v A", tel.CurrentA, wantA)
+	}
+	if want := eclipse.OpenCircuitV(pct) - wantA*eclipse.InternalOhm; !near(tel.VoltageV, want, 1e-6) {
+		t.Fatalf("voltage %v V, want %v V", tel.VoltageV, want)
+	}
+
+	tests := []struct {
+		pct      float64
+		brownout bool
+		critical bool
+	}{
+		{100, false, false},
+		{30, false, false},
+		{25, true, false},
+		{10, true, true},
+		{0, true, true},
+	}
+	for _, tt := range tests {
+		v := eclipse.OpenCircuitV(tt.pct)
+		if eclipse.Brownout(v) != tt.brownout || eclipse.Critical(v) != tt.critical {
+			t.Fatalf("%v%% (%v V): brownout %v critical %v, want %v %v", tt.pct, v, eclipse.Brownout(v), eclipse.Critical(v), tt.brownout, tt.critical)
+		}
+	}
+}
+
+func TestPowerOrbit(t *testing.T) {
+	p := NewPower(DefaultPower)
+	sunlit := 0
+	steps := int(DefaultPower.OrbitS / 60)
+	for i := 0; i < steps; i++ {
+		if p.Sunlit() {
+			sunlit++
+		}
+		p.Step(50, 60, PowerLoads{})
+	}
+	if got := float64(sunlit) / float64(steps); !near(got, DefaultPower.SunlitFraction, 0.02) {
+		t.Fatalf("sunlit %.2f of the orbit, want %.2f", got, DefaultPower.SunlitFraction)
+	}
+}
@@ -0,0 +1,98 @@
+package sim
+
+import (
+	"math"
+	"math/rand"
+)
+
+// Thermal model
+//
+// The module exchanges heat with its surroundings (ambient / sink
+// temperature) by Newton cooling. The camera heats it while INSPECT_PANEL
+// runs, thruster firings heat it through ManeuverModel.HeatCPerNs, and a
+// thermostat heater keeps it from getting too cold. Sensor noise comes from
+// a seeded source so a run can be reproduced exactly.
+
+type ThermalModel struct {
+	AmbientC        float64 // Temperature the module cools (or warms) towards
+	CoolingPerS     float64 // Fraction of (T - ambient) lost per second
+	CameraCPerS     float64 // Heating while the camera is on
+	HeaterCPerS     float64 // Heating while the heater is on
+	HeaterOnBelowC  float64 // Thermostat switches the heater on below this
+	HeaterOffAboveC float64 // ... and off again above this
+	ThrottleAboveC  float64 // Maneuvers are throttled above this
+	MinThrottle     float64 // Throttle reached at SafeAboveC
+	SafeAboveC      float64 // Overtemp, the module is forced SAFE at or above this
+	NoiseC          float64 // Standard deviation of the sensor noise per step
+	Seed            int64   // Seed of the noise source
+}
+
+var DefaultThermal = ThermalModel{
+	AmbientC:        0.0,
+	CoolingPerS:     0.005,
+	CameraCPerS:     0.5,
+	HeaterCPerS:     0.2,
+	HeaterOnBelowC:  5.0,
+	HeaterOffAboveC: 10.0,
+	ThrottleAboveC:  45.0,
+	MinThrottle:     0.25,
+	SafeAboveC:      60.0,
+	NoiseC:          0.05,
+	Seed:            1,
+}
+
+// Loads are the heat sources switched on by the module
+type Loads struct {
+	Camera bool
+}
+
+// Thermal is a running ThermalModel. It is not safe for concurrent use,
+// the module state calls it with its lock held.
+type Thermal struct {
+	ThermalModel
+	rng    *rand.Rand
+	heater bool
+}
+
+func NewThermal(m ThermalModel) *Thermal {
+	return &Thermal{ThermalModel: m, rng: rand.New(rand.NewSource(m.Seed))}
+}
+
+// Heater reports whether the thermostat has the heater on
+func (t *Thermal) Heater() bool {
+	return t.heater
+}
+
+// Step advances the temperature by dt seconds
+func (t *Thermal) Step(tempC, dt float64, loads Loads) float64 {
+	if tempC < t.HeaterOnBelowC {
+		t.heater = true
+	} else if tempC > t.HeaterOffAboveC {
+		t.heater = false
+	}
+
+	// Exact solution of Newton cooling over dt, stable for any step size
+	tempC = t.AmbientC + (tempC-t.AmbientC)*math.Exp(-t.CoolingPerS*dt)
+	if loads.Camera {
+		tempC += t.CameraCPerS * dt
+	}
+	if t.heater {
+		tempC += t.HeaterCPerS * dt
+	}
+	return tempC + t.rng.NormFloat64()*t.NoiseC
+}
+
+// Throttle is the thrust fraction allowed at tempC: 1 up to ThrottleAboveC,
+// then falling linearly to MinThrottle at SafeAboveC
+func (m ThermalModel) Throttle(tempC float64) float64 {
+	if tempC <= m.ThrottleAboveC || m.SafeAboveC <= m.ThrottleAboveC {
+		return 1
+	}
+	f := (tempC - m.ThrottleAboveC) / (m.SafeAboveC - m.ThrottleAboveC)
+	return math.Max(m.MinThrottle, 1-f*(1-m.MinThrottle))
+}
+
+// Overtemp reports whether tempC forces the module SAFE
+func (m ThermalModel) Overtemp(tempC float64) bool {
+	return tempC >= m.SafeAboveC
+}
@@ -0,0 +1,95 @@
+package sim
+
+import (
+	"math"
+	"testing"
+)
+
+func TestThermalStep(t *testing.T) {
+	quiet := DefaultThermal
+	quiet.NoiseC = 0
+	tests := []struct {
+		name  string
+		start float64
+		dt    float64
+		loads Loads
+		want  float64
+	}{
+		{name: "cools to ambient", start: 50, dt: 100, want: 50 * math.Exp(-quiet.CoolingPerS*100)},
+		{name: "camera heats", start: 20, dt: 10, loads: Loads{Camera: true}, want: 20*math.Exp(-quiet.CoolingPerS*10) + quiet.CameraCPerS*10},
+		{name: "heater on when cold", start: 2, dt: 10, want: 2*math.Exp(-quiet.CoolingPerS*10) + quiet.HeaterCPerS*10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewThermal(quiet).Step(tt.start, tt.dt, tt.loads)
+			if !near(got, tt.want, 1e-9) {
+				t.Fatalf("Step(%v, %v) = %v, want %v", tt.start, tt.dt, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestThermalHeaterHysteresis(t *testing.T) {
+	th := NewThermal(DefaultThermal)
+	// Switches on below HeaterOnBelowC, stays on up to HeaterOffAboveC,
+	// then stays off until it is cold again
+	tests := []struct {
+		temp   float64
+		heater bool
+	}{
+		{12, false},
+		{7, false},
+		{4.9, true},
+		{7, true},
+		{10, true},
+		{10.1, false},
+		{7, false},
+		{4, true},
+	}
+	for _, tt := range tests {
+		th.Step(tt.temp, 1, Loads{})
+		if th.Heater() != tt.heater {
+			t.Fatalf("heater %v at %v°C, want %v", th.Heater(), tt.temp, tt.heater)
+		}
+	}
+}
+
+func TestThermalSeeded(t *testing.T) {
+	run := func(seed int64) []float64 {
+		m := DefaultThermal
+		m.Seed = seed
+		th := NewThermal(m)
+		temps := []float64{20}
+		for i := 0; i < 10; i++ {
+			temps = append(temps, th.Step(temps[len(temps)-1], 1, Loads{}))
+		}
+		return temps
+	}
+	a, b, other := run(1), run(1), run(2)
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("step %d: %v and %v with the same seed", i, a[i], b[i])
+		}
+	}
+	if a[len(a)-1] == other[len(other)-1] {
+		t.Fatal("different seeds gave the same noise")
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	tests := []struct {
+		temp float64
+		want float64
+	}{
+		{20, 1},
+		{45, 1},
+		{52.5, 1 - 0.5*(1-DefaultThermal.MinThrottle)},
+		{60, DefaultThermal.MinThrottle},
+		{80, DefaultThermal.MinThrottle},
+	}
+	for _, tt := range tests {
+		if got := DefaultThermal.Throttle(tt.temp); !near(got, tt.want, 1e-9) {
+			t.Fatalf("Throttle(%v) = %v, want %v", tt.temp, got, tt.want)
+		}
+	}
+}
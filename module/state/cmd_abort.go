@@ -0,0 +1,97 @@
+package state
+
+import (
+	"communication_module/command"
+	"communication_module/fsm"
+	"communication_module/logger"
+	"communication_module/protocol"
+	"communication_module/transport"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+func init() {
+	Register(Spec{
+		Name:        "ABORT",
+		Description: "Cancel the in flight command msg_id",
+		Args:        func() command.Args { return &command.AbortArgs{} },
+		Policy:      Policy{AllowedIn: AnyState, InSafe: "Only stops commands"},
+		Timeout:     5 * time.Second,
+		Handler: func(cmd command.Command, args command.Args, ms *ModuleState, ctx context.Context, tr transport.Transport) protocol.Reply {
+			target := *args.(*command.AbortArgs).MsgID
+			aborted, ok := ms.inflight.Abort(target)
+			if !ok {
+				return protocol.Fail(cmd.MSG_ID, cmd.CMD, protocol.NOT_IN_FLIGHT, fmt.Sprintf("No command %s in flight", target))
+			}
+			logger.Warning("Aborting ", aborted, " ", target)
+			reply := protocol.NewReply(cmd.MSG_ID, cmd.CMD, protocol.RESULT, "Abort requested")
+			reply.Data["aborted"] = []string{target}
+			return reply
+		},
+	})
+	Register(Spec{
+		Name:        "ABORT_ALL",
+		Description: "Cancel every in flight command",
+		Policy:      Policy{AllowedIn: AnyState, InSafe: "Only stops commands"},
+		Timeout:     5 * time.Second,
+		Handler: func(cmd command.Command, args command.Args, ms *ModuleState, ctx context.Context, tr transport.Transport) protocol.Reply {
+			aborted := ms.inflight.AbortAll(cmd.MSG_ID)
+			logger.Warning("Aborting all commands: ", aborted)
+			reply := protocol.NewReply(cmd.MSG_ID, cmd.CMD, protocol.RESULT, "Abort requested")
+			reply.Data["aborted"] = aborted
+			return reply
+		},
+	})
+}
+
+// stopped builds the final reply of a command whose context ended and moves
+// the module to `to`. An ABORT (or shutdown) gives a RESULT with reason
+// ABORTED, running out of time an ERROR with reason TIMEOUT. Both report
+// the state transition that was caused.
+func stopped(ctx context.Context, cmd command.Command, ms *ModuleState, to fsm.State) protocol.Reply {
+	var reply protocol.Reply
+	cause := context.Cause(ctx)
+	if errors.Is(cause, context.DeadlineExceeded) {
+		reply = protocol.Fail(cmd.MSG_ID, cmd.CMD, protocol.TIMEOUT, "Command timed out")
+	} else {
+		reply = protocol.NewReply(cmd.MSG_ID, cmd.CMD, protocol.RESULT, "Command aborted")
+		reply.Reason = protocol.ABORTED
+	}
+	reply.Data["outcome"] = "ABORTED"
+	reply.Data["cause"] = cause.Error()
+
+	from := ms.machine.Current()
+	if err := ms.setStatusFor(cmd.MSG_ID, to, string(reply.Reason)); err != nil {
+		// SAFE (or another command) got in first, report where the module is
+		failed := interrupted(cmd, fmt.Sprintf("Command stopped, module not back to %s", to), ms)
+		failed.Data["outcome"] = reply.Data["outcome"]
+		failed.Data["cause"] = reply.Data["cause"]
+		failed.Data["transition_error"] = err.Error()
+		return failed
+	}
+	reply.Data["transition"] = map[string]interface{}{"from": from, "to": to}
+	return reply
+}
+
+// interrupted is the ERROR of a command that lost the module to another
+// transition: the cause of the SAFE latch as reason, INVALID_STATE outside
+// SAFE. The reply carries the state the module is in.
+func interrupted(cmd command.Command, message string, ms *ModuleState) protocol.Reply {
+	current := ms.machine.Current()
+	latch, latched := ms.Latch()
+	reason := protocol.INVALID_STATE
+	if current == fsm.SAFE {
+		reason = protocol.MODULE_SAFE
+		if latched {
+			reason = protocol.Reason(latch.Cause)
+		}
+	}
+	reply := protocol.Fail(cmd.MSG_ID, cmd.CMD, reason, message)
+	reply.Data["status"] = current
+	if latched {
+		reply.Data["latch"] = latch
+	}
+	return reply
+}
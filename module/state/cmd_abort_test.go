@@ -0,0 +1,145 @@
+package state
+
+import (
+	"communication_module/command"
+	"communication_module/fsm"
+	"communication_module/protocol"
+	"communication_module/transport"
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestStoppedReportsTransition(t *testing.T) {
+	tests := []struct {
+		name   string
+		from   fsm.State
+		status protocol.Status
+		reason protocol.Reason
+	}{
+		{name: "back to IDLE", from: fsm.ACTIVE, status: protocol.RESULT, reason: protocol.ABORTED},
+		// SAFE got in before the command stopped, it stays latched
+		{name: "latched SAFE", from: fsm.SAFE, status: protocol.ERROR, reason: protocol.HEARTBEAT_LOST},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ms := Initialize(DefaultConfig)
+			if err := ms.SetStatus(tt.from, string(protocol.HEARTBEAT_LOST)); err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithCancelCause(context.Background())
+			cancel(ErrAborted)
+
+			reply := stopped(ctx, command.Command{MSG_ID: "m-1", CMD: "INSPECT_PANEL"}, ms, fsm.IDLE)
+			if reply.Status != tt.status || reply.Reason != tt.reason {
+				t.Fatalf("reply %s %s (%s), want %s %s", reply.Status, reply.Reason, reply.Message, tt.status, tt.reason)
+			}
+			transition, reported := reply.Data["transition"].(map[string]interface{})
+			if ok := tt.status == protocol.RESULT; reported != ok {
+				t.Fatalf("transition %v reported %v, want %v", reply.Data["transition"], reported, ok)
+			}
+			if reported && (transition["from"] != tt.from || transition["to"] != fsm.IDLE) {
+				t.Fatalf("transition %v, want %s -> IDLE", transition, tt.from)
+			}
+			if !reported && reply.Data["status"] != fsm.SAFE {
+				t.Fatalf("status %v, want SAFE", reply.Data["status"])
+			}
+		})
+	}
+}
+
+// inFlight waits until n commands are in flight
+func inFlight(t *testing.T, ms *ModuleState, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		ms.inflight.mu.Lock()
+		got := len(ms.inflight.cmds)
+		ms.inflight.mu.Unlock()
+		if got == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d commands in flight, want %d", got, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// checkAborted fails unless reply is the RESULT: ABORTED of a command that
+// was running (ACTIVE -> IDLE) or queued (no transition)
+func checkAborted(t *testing.T, reply protocol.Reply, running bool) {
+	t.Helper()
+	if reply.Status != protocol.RESULT || reply.Reason != protocol.ABORTED {
+		t.Fatalf("%s reply %s %s (%s), want RESULT ABORTED", reply.MsgID, reply.Status, reply.Reason, reply.Message)
+	}
+	transition, ok := reply.Data["transition"].(map[string]interface{})
+	if ok != running {
+		t.Fatalf("%s transition %v, want one %v", reply.MsgID, reply.Data["transition"], running)
+	}
+	if running && (transition["from"] != fsm.ACTIVE || transition["to"] != fsm.IDLE) {
+		t.Fatalf("%s transition %v, want ACTIVE -> IDLE", reply.MsgID, transition)
+	}
+}
+
+func TestAbortRunning(t *testing.T) {
+	tests := []command.Command{
+		maneuver,
+		{MSG_ID: "m-1", CMD: "INSPECT_PANEL"},
+	}
+	for _, cmd := range tests {
+		t.Run(cmd.CMD, func(t *testing.T) {
+			ms := Initialize(DefaultConfig)
+			replyCh := start(t, ms, cmd)
+
+			abort := runCommand(command.Command{MSG_ID: "a-1", CMD: "ABORT", ARGS: json.RawMessage(`{"msg_id": "m-1"}`)}, ms, context.Background(), transport.NewMemory(10))
+			if abort.Status != protocol.RESULT || fmt.Sprint(abort.Data["aborted"]) != "[m-1]" {
+				t.Fatalf("ABORT %s %s (%s) aborted %v, want RESULT aborting m-1", abort.Status, abort.Reason, abort.Message, abort.Data["aborted"])
+			}
+			checkAborted(t, <-replyCh, true)
+
+			if !ms.Is(fsm.IDLE) {
+				t.Fatalf("module %s, want IDLE", ms.Current())
+			}
+			release, err := ms.executor.Acquire(context.Background(), "next", REJECT_WHEN_BUSY)
+			if err != nil {
+				t.Fatalf("executor slot not released: %v", err)
+			}
+			release()
+		})
+	}
+}
+
+func TestAbortAll(t *testing.T) {
+	ms := Initialize(DefaultConfig)
+	running := start(t, ms, command.Command{MSG_ID: "m-1", CMD: "INSPECT_PANEL"})
+	queued := make(chan protocol.Reply, 2)
+	for _, msgID := range []string{"m-2", "m-3"} {
+		cmd := command.Command{MSG_ID: msgID, CMD: "INSPECT_PANEL"}
+		go func() { queued <- runCommand(cmd, ms, context.Background(), transport.NewMemory(10)) }()
+	}
+	inFlight(t, ms, 3)
+
+	abort := runCommand(command.Command{MSG_ID: "a-1", CMD: "ABORT_ALL"}, ms, context.Background(), transport.NewMemory(10))
+	if abort.Status != protocol.RESULT || fmt.Sprint(abort.Data["aborted"]) != "[m-1 m-2 m-3]" {
+		t.Fatalf("ABORT_ALL %s (%s) aborted %v, want m-1 m-2 m-3", abort.Status, abort.Message, abort.Data["aborted"])
+	}
+	checkAborted(t, <-running, true)
+	checkAborted(t, <-queued, false)
+	checkAborted(t, <-queued, false)
+
+	inFlight(t, ms, 0)
+	if !ms.Is(fsm.IDLE) {
+		t.Fatalf("module %s, want IDLE", ms.Current())
+	}
+}
+
+func TestAbortNotInFlight(t *testing.T) {
+	ms := Initialize(DefaultConfig)
+	reply := runCommand(command.Command{MSG_ID: "a-1", CMD: "ABORT", ARGS: json.RawMessage(`{"msg_id": "m-9"}`)}, ms, context.Background(), transport.NewMemory(10))
+	if reply.Status != protocol.ERROR || reply.Reason != protocol.NOT_IN_FLIGHT {
+		t.Fatalf("reply %s %s (%s), want ERROR NOT_IN_FLIGHT", reply.Status, reply.Reason, reply.Message)
+	}
+}
@@ -0,0 +1,39 @@
+package state
+
+import (
+	"communication_module/command"
+	"communication_module/logger"
+	"communication_module/protocol"
+	"communication_module/transport"
+	"context"
+	"fmt"
+	"time"
+)
+
+func init() {
+	Register(Spec{
+		Name:        "HEALTH_CHECK",
+		Description: "Report battery, voltage, current, temperature and status",
+		Policy:      Policy{AllowedIn: AnyState, InSafe: "Spec SAFE command, reports the state"},
+		Timeout:     5 * time.Second,
+		Handler: func(cmd command.Command, args command.Args, ms *ModuleState, ctx context.Context, tr transport.Transport) protocol.Reply {
+			logger.Info("Performing health check...")
+			return HealthCheck(cmd, ms, ctx, tr)
+		},
+	})
+}
+
+func HealthCheck(cmd command.Command, ms *ModuleState, ctx context.Context, tr transport.Transport) protocol.Reply {
+	logger.Plain("Performing health check...")
+
+	logger.Plain(fmt.Sprintf("Starting Health Check: %s", ms.machine.Current()))
+	snapshot := ms.GetSnapshot()
+	reply := protocol.NewReply(cmd.MSG_ID, cmd.CMD, protocol.RESULT, "Health check completed")
+	reply.Data["battery"] = snapshot.BatteryLevel
+	reply.Data["voltage"] = snapshot.VoltageV
+	reply.Data["current"] = snapshot.CurrentA
+	reply.Data["temperature"] = snapshot.Temperature
+	reply.Data["status"] = snapshot.Status
+
+	return reply
+}
@@ -0,0 +1,57 @@
+package state
+
+import (
+	"communication_module/command"
+	"communication_module/protocol"
+	"communication_module/transport"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// HELP and CAPABILITIES describe the registered commands to the host
+
+func init() {
+	Register(Spec{
+		Name:        "HELP",
+		Description: "List the commands with their arguments",
+		Policy:      Policy{AllowedIn: AnyState, InSafe: "Read only"},
+		Timeout:     5 * time.Second,
+		Handler: func(cmd command.Command, args command.Args, ms *ModuleState, ctx context.Context, tr transport.Transport) protocol.Reply {
+			lines := []string{}
+			for _, spec := range Commands() {
+				line := spec.Name
+				if spec.Args != nil {
+					schema := command.Schema(spec.Args())
+					names := make([]string, 0, len(schema))
+					for name := range schema {
+						names = append(names, name)
+					}
+					sort.Strings(names)
+					line += " " + strings.Join(names, " ")
+				}
+				lines = append(lines, fmt.Sprintf("%s - %s", line, spec.Description))
+			}
+			reply := protocol.NewReply(cmd.MSG_ID, cmd.CMD, protocol.RESULT, "Available commands")
+			reply.Data["help"] = lines
+			return reply
+		},
+	})
+	Register(Spec{
+		Name:        "CAPABILITIES",
+		Description: "Describe every command: arguments, allowed states, exclusivity and timeout",
+		Policy:      Policy{AllowedIn: AnyState, InSafe: "Read only"},
+		Timeout:     5 * time.Second,
+		Handler: func(cmd command.Command, args command.Args, ms *ModuleState, ctx context.Context, tr transport.Transport) protocol.Reply {
+			capabilities := []Capability{}
+			for _, spec := range Commands() {
+				capabilities = append(capabilities, spec.Capability())
+			}
+			reply := protocol.NewReply(cmd.MSG_ID, cmd.CMD, protocol.RESULT, "Capabilities")
+			reply.Data["commands"] = capabilities
+			return reply
+		},
+	})
+}
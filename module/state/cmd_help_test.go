@@ -0,0 +1,96 @@
+package state
+
+import (
+	"communication_module/command"
+	"communication_module/protocol"
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+func TestHelp(t *testing.T) {
+	got := replies(t, Initialize(DefaultConfig), command.Command{MSG_ID: "m-1", CMD: "HELP"})
+	last := got[len(got)-1]
+	if last.Status != protocol.RESULT {
+		t.Fatalf("reply %s %s (%s), want RESULT", last.Status, last.Reason, last.Message)
+	}
+	var lines []string
+	for _, line := range last.Data["help"].([]interface{}) {
+		lines = append(lines, line.(string))
+	}
+	if len(lines) != len(Commands()) {
+		t.Fatalf("%d help lines for %d commands", len(lines), len(Commands()))
+	}
+	for _, want := range []string{
+		"PERFORM_MANEUVER x y z - Burn the x, y, z delta-v (cm/s per body axis)",
+		"ABORT msg_id - Cancel the in flight command msg_id",
+		"HEALTH_CHECK - Report battery, voltage, current, temperature and status",
+	} {
+		if !slices.Contains(lines, want) {
+			t.Errorf("help %q does not have %q", lines, want)
+		}
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	got := replies(t, Initialize(DefaultConfig), command.Command{MSG_ID: "m-1", CMD: "CAPABILITIES"})
+	last := got[len(got)-1]
+	if last.Status != protocol.RESULT {
+		t.Fatalf("reply %s %s (%s), want RESULT", last.Status, last.Reason, last.Message)
+	}
+	// As the host decodes them
+	data, err := json.Marshal(last.Data["commands"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var capabilities []Capability
+	if err := json.Unmarshal(data, &capabilities); err != nil {
+		t.Fatal(err)
+	}
+	if len(capabilities) != len(Commands()) {
+		t.Fatalf("%d capabilities for %d commands", len(capabilities), len(Commands()))
+	}
+	byName := map[string]Capability{}
+	for _, c := range capabilities {
+		byName[c.Name] = c
+	}
+
+	tests := []struct {
+		name      string
+		args      map[string]string
+		states    []string
+		exclusive bool
+		whenBusy  BusyMode
+		timeoutMs int64
+	}{
+		{name: "PERFORM_MANEUVER", args: map[string]string{"x": "number", "y": "number", "z": "number"}, states: []string{"IDLE", "ACTIVE"}, exclusive: true, whenBusy: REJECT_WHEN_BUSY, timeoutMs: 30000},
+		{name: "INSPECT_PANEL", states: []string{"IDLE", "ACTIVE"}, exclusive: true, whenBusy: QUEUE_WHEN_BUSY, timeoutMs: 10000},
+		{name: "ABORT", args: map[string]string{"msg_id": "string"}, states: []string{"IDLE", "ACTIVE", "SAFE"}, timeoutMs: 5000},
+		{name: "HEALTH_CHECK", states: []string{"IDLE", "ACTIVE", "SAFE"}, timeoutMs: 5000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, ok := byName[tt.name]
+			if !ok {
+				t.Fatalf("no capability %s", tt.name)
+			}
+			if len(c.Args) != len(tt.args) {
+				t.Fatalf("args %v, want %v", c.Args, tt.args)
+			}
+			for name, typ := range tt.args {
+				if c.Args[name] != typ {
+					t.Fatalf("args %v, want %v", c.Args, tt.args)
+				}
+			}
+			if !slices.Equal(c.AllowedStates, tt.states) {
+				t.Fatalf("allowed states %v, want %v", c.AllowedStates, tt.states)
+			}
+			if c.Exclusive != tt.exclusive || c.WhenBusy != tt.whenBusy {
+				t.Fatalf("exclusive %v when busy %q, want %v %q", c.Exclusive, c.WhenBusy, tt.exclusive, tt.whenBusy)
+			}
+			if c.TimeoutMs != tt.timeoutMs {
+				t.Fatalf("timeout %d ms, want %d ms", c.TimeoutMs, tt.timeoutMs)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package state
+
+import (
+	"communication_module/command"
+	"communication_module/history"
+	"communication_module/logger"
+	"communication_module/protocol"
+	"communication_module/transport"
+	"context"
+	"fmt"
+	"time"
+)
+
+func init() {
+	Register(Spec{
+		Name:        "GET_HISTORY",
+		Description: "Return recorded events and replies, of one msg_id or a from / to time range",
+		Args:        func() command.Args { return &command.HistoryArgs{} },
+		Policy:      Policy{AllowedIn: AnyState, InSafe: "Read only"},
+		Timeout:     5 * time.Second,
+		Handler: func(cmd command.Command, args command.Args, ms *ModuleState, ctx context.Context, tr transport.Transport) protocol.Reply {
+			return GetHistory(cmd, args.(*command.HistoryArgs), ms.History(), ctx)
+		},
+	})
+}
+
+// GetHistory queries log, the history the module records to
+func GetHistory(cmd command.Command, args *command.HistoryArgs, log *history.Log, ctx context.Context) protocol.Reply {
+	if log == nil {
+		return protocol.Fail(cmd.MSG_ID, cmd.CMD, protocol.HISTORY_UNAVAILABLE, "Module keeps no history")
+	}
+
+	limit := int64(*args.Limit)
+	var entries []history.Entry
+	var err error
+	if args.MsgID != nil {
+		entries, err = log.ByMsgID(ctx, *args.MsgID, limit)
+	} else {
+		from, to, _ := args.Range()
+		entries, err = log.Range(ctx, from, to, limit)
+	}
+	if err != nil {
+		logger.Error("History query failed: ", err)
+		return protocol.Fail(cmd.MSG_ID, cmd.CMD, protocol.HISTORY_UNAVAILABLE, err.Error())
+	}
+
+	reply := protocol.NewReply(cmd.MSG_ID, cmd.CMD, protocol.RESULT, fmt.Sprintf("%d history entries", len(entries)))
+	reply.Data["entries"] = entries
+	reply.Data["count"] = len(entries)
+	// Would nest earlier history in the history
+	reply.Ephemeral = true
+	return reply
+}
@@ -0,0 +1,75 @@
+package state
+
+import (
+	"communication_module/command"
+	"communication_module/history"
+	"communication_module/protocol"
+	"communication_module/transport"
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestGetHistory(t *testing.T) {
+	ctx := context.Background()
+	tr := transport.NewMemory(100)
+	// Not the default stream, GET_HISTORY must read the module's history
+	hist, err := history.New(tr, history.Config{Stream: "TEST_HISTORY", MaxLen: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := func(e history.Entry) {
+		e.Payload = json.RawMessage(`{}`)
+		if err := hist.Record(ctx, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	record(history.Entry{Kind: "REPLY", Status: "ACK", MsgID: "m-1"})
+	record(history.Entry{Kind: "STATUS", MsgID: "e-1"})
+	time.Sleep(5 * time.Millisecond)
+	split := time.Now()
+	time.Sleep(5 * time.Millisecond)
+	record(history.Entry{Kind: "TRANSITION", MsgID: "e-2", CorrelationID: "m-1"})
+	record(history.Entry{Kind: "REPLY", Status: "RESULT", MsgID: "m-1"})
+	record(history.Entry{Kind: "REPLY", Status: "RESULT", MsgID: "m-2"})
+
+	tests := []struct {
+		name string
+		args string
+		want []string // Kind/Status of the entries, oldest first
+	}{
+		{name: "by msg_id", args: `{"msg_id": "m-1"}`, want: []string{"REPLY/ACK", "TRANSITION/", "REPLY/RESULT"}},
+		{name: "by msg_id limited", args: `{"msg_id": "m-1", "limit": 1}`, want: []string{"REPLY/ACK"}},
+		{name: "unknown msg_id", args: `{"msg_id": "m-9"}`, want: []string{}},
+		{name: "from", args: fmt.Sprintf(`{"from": %q}`, split.Format(time.RFC3339Nano)), want: []string{"TRANSITION/", "REPLY/RESULT", "REPLY/RESULT"}},
+		{name: "to", args: fmt.Sprintf(`{"to": %q}`, split.Format(time.RFC3339Nano)), want: []string{"REPLY/ACK", "STATUS/"}},
+		{name: "everything", args: `{}`, want: []string{"REPLY/ACK", "STATUS/", "TRANSITION/", "REPLY/RESULT", "REPLY/RESULT"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ms := Initialize(DefaultConfig)
+			ms.SetHistory(hist)
+			reply := ProcessCommand(command.Command{MSG_ID: "h-1", CMD: "GET_HISTORY", ARGS: json.RawMessage(tt.args)}, ms, ctx, tr)
+			if reply.Status != protocol.RESULT {
+				t.Fatalf("reply %s %s (%s), want RESULT", reply.Status, reply.Reason, reply.Message)
+			}
+			entries := reply.Data["entries"].([]history.Entry)
+			got := make([]string, len(entries))
+			for i, e := range entries {
+				got[i] = e.Kind + "/" + e.Status
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Fatalf("entries %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetHistoryUnavailable(t *testing.T) {
+	reply := ProcessCommand(command.Command{MSG_ID: "h-1", CMD: "GET_HISTORY"}, Initialize(DefaultConfig), context.Background(), transport.NewMemory(10))
+	if reply.Status != protocol.ERROR || reply.Reason != protocol.HISTORY_UNAVAILABLE {
+		t.Fatalf("reply %s %s, want ERROR HISTORY_UNAVAILABLE", reply.Status, reply.Reason)
+	}
+}
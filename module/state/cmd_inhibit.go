@@ -0,0 +1,38 @@
+package state
+
+import (
+	"communication_module/command"
+	"communication_module/logger"
+	"communication_module/protocol"
+	"communication_module/transport"
+	"context"
+	"time"
+)
+
+func init() {
+	Register(Spec{
+		Name:        "SET_THRUST_INHIBIT",
+		Description: "Assert or clear the thrust inhibit",
+		Args:        func() command.Args { return &command.InhibitArgs{} },
+		Policy:      Policy{AllowedIn: AnyState, InSafe: "Spec SAFE command, the inhibit only prevents thrust"},
+		Timeout:     5 * time.Second,
+		Handler: func(cmd command.Command, args command.Args, ms *ModuleState, ctx context.Context, tr transport.Transport) protocol.Reply {
+			return SetThrustInhibit(cmd, *args.(*command.InhibitArgs).Inhibit, ms, ctx, tr)
+		},
+	})
+}
+
+func SetThrustInhibit(cmd command.Command, inhibit bool, ms *ModuleState, ctx context.Context, tr transport.Transport) protocol.Reply {
+	if inhibit {
+		logger.Warning("Thrust inhibit asserted by host")
+	} else {
+		logger.Info("Thrust inhibit cleared by host")
+	}
+	ms.Modify(func(v *Values) {
+		v.ThrustInhibit = inhibit
+	})
+
+	reply := protocol.NewReply(cmd.MSG_ID, cmd.CMD, protocol.RESULT, "Thrust inhibit updated")
+	reply.Data["thrust_inhibit"] = inhibit
+	return reply
+}
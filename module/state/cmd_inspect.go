@@ -0,0 +1,66 @@
+package state
+
+import (
+	"communication_module/command"
+	"communication_module/fsm"
+	"communication_module/logger"
+	"communication_module/protocol"
+	"communication_module/transport"
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func init() {
+	Register(Spec{
+		Name:        "INSPECT_PANEL",
+		Description: "Photograph the panel, returns the image uri",
+		Policy:      Policy{AllowedIn: NotSafe, Exclusive: true, WhenBusy: QUEUE_WHEN_BUSY},
+		Admit:       admitIdle,
+		Timeout:     10 * time.Second,
+		Handler: func(cmd command.Command, args command.Args, ms *ModuleState, ctx context.Context, tr transport.Transport) protocol.Reply {
+			logger.Info("Inspecting panel")
+			return InspectPanel(cmd, ms, ctx, tr)
+		},
+	})
+}
+
+func InspectPanel(cmd command.Command, ms *ModuleState, ctx context.Context, tr transport.Transport) protocol.Reply {
+
+	// Logic to inspect the panel
+	logger.Plain(fmt.Sprintf("Starting Panel Inspection: %s", ms.machine.Current()))
+
+	if err := ms.setStatusFor(cmd.MSG_ID, fsm.ACTIVE, cmd.CMD); err != nil {
+		logger.Warning(fmt.Sprintf("Cannot inspect panel while module is not IDLE. Current status: %s", ms.machine.Current()))
+		return protocol.Fail(cmd.MSG_ID, cmd.CMD, protocol.MODULE_NOT_IDLE, err.Error())
+	}
+
+	// The camera heats the module while it is on
+	ms.Modify(func(v *Values) { v.CameraOn = true })
+	defer ms.Modify(func(v *Values) { v.CameraOn = false })
+
+	n := rand.Intn(2000-200+1) + 200 // Random time to do this between 200ms and 2s
+	select {
+	case <-ctx.Done():
+		logger.Warning("Panel inspection stopped: ", context.Cause(ctx))
+		return stopped(ctx, cmd, ms, fsm.IDLE)
+	case <-time.After(time.Duration(n) * time.Millisecond): // Simulate time taken to take a photo
+	}
+
+	logger.Plain("Sending output of INSPECT_PANEL to MODULE_Q")
+	reply := protocol.NewReply(cmd.MSG_ID, cmd.CMD, protocol.RESULT, "Photograph taken")
+	reply.Data["result"] = "OK"
+	reply.Data["event"] = "image_captured"
+	reply.Data["uri"] = fmt.Sprintf("uri://%s", uuid.New().String())
+
+	if err := ms.setStatusFor(cmd.MSG_ID, fsm.IDLE, cmd.CMD); err != nil {
+		logger.Error("Panel inspected, module not back to IDLE: ", err)
+		failed := interrupted(cmd, "Photograph taken, module not back to IDLE", ms)
+		failed.Data["uri"] = reply.Data["uri"]
+		return failed
+	}
+	return reply
+}
@@ -0,0 +1,182 @@
+package state
+
+import (
+	"communication_module/command"
+	"communication_module/fsm"
+	"communication_module/logger"
+	"communication_module/protocol"
+	"communication_module/sim"
+	"communication_module/transport"
+	"context"
+	"fmt"
+	"time"
+)
+
+// ManeuverStep is the integration step of a burn
+var ManeuverStep = 50 * time.Millisecond
+
+func init() {
+	Register(Spec{
+		Name:        "PERFORM_MANEUVER",
+		Description: "Burn the x, y, z delta-v (cm/s per body axis)",
+		Args:        func() command.Args { return &command.ManeuverArgs{} },
+		Policy:      Policy{AllowedIn: NotSafe, Exclusive: true, WhenBusy: REJECT_WHEN_BUSY},
+		Admit: func(cmd command.Command, args command.Args, ms *ModuleState) (protocol.Reply, bool) {
+			if ms.Values().ThrustInhibit {
+				logger.Warning("Thrust rejected: thrust inhibit asserted.")
+				return protocol.Reject(cmd.MSG_ID, cmd.CMD, protocol.THRUST_INHIBITED, "Thrust inhibit asserted"), false
+			}
+			if rejected, ok := admitIdle(cmd, args, ms); !ok {
+				return rejected, false
+			}
+			if voltage := ms.voltage(); ms.power.Brownout(voltage) {
+				logger.Warning("Thrust rejected: brownout.")
+				reply := protocol.Reject(cmd.MSG_ID, cmd.CMD, protocol.BROWNOUT,
+					fmt.Sprintf("battery at %.2f V, below %.2f V", voltage, ms.power.BrownoutV))
+				reply.Data["voltage_v"] = voltage
+				return reply, false
+			}
+			return ms.admitBudget(cmd, args.(*command.ManeuverArgs).Vector())
+		},
+		Timeout: 30 * time.Second,
+		Handler: func(cmd command.Command, args command.Args, ms *ModuleState, ctx context.Context, tr transport.Transport) protocol.Reply {
+			logger.Info("Activating thrust...")
+			return PerformThrust(cmd, args.(*command.ManeuverArgs).Vector(), ms, ctx, tr)
+		},
+	})
+}
+
+// cmPerS converts the commanded delta-v of the arguments (cm/s) to m/s
+func cmPerS(v command.Vector) command.Vector {
+	return v.Scale(0.01)
+}
+
+// admitBudget rejects a maneuver the propellant or battery can't pay for
+func (ms *ModuleState) admitBudget(cmd command.Command, commanded command.Vector) (protocol.Reply, bool) {
+	v := ms.Values()
+	plan := ms.maneuver.Plan(cmPerS(commanded), v.PropellantKg)
+
+	if plan.PropellantKg > v.PropellantKg {
+		reply := protocol.Reject(cmd.MSG_ID, cmd.CMD, protocol.INSUFFICIENT_PROPELLANT,
+			fmt.Sprintf("needs %.3f kg propellant, %.3f kg left", plan.PropellantKg, v.PropellantKg))
+		reply.Data["plan"] = plan
+		return reply, false
+	}
+	minBattery := ms.prechecks.Policy().MinBatteryPct
+	if drain := ms.power.Pct(plan.EnergyWh); v.BatteryLevel-drain <= minBattery {
+		reply := protocol.Reject(cmd.MSG_ID, cmd.CMD, protocol.INSUFFICIENT_POWER,
+			fmt.Sprintf("needs %.2f%% battery, would end below %.0f%%", drain, minBattery))
+		reply.Data["plan"] = plan
+		return reply, false
+	}
+	return protocol.Reply{}, true
+}
+
+// maneuverData is the state of a burn reported in PROGRESS and RESULT
+func maneuverData(reply protocol.Reply, burn *sim.Burn, ms *ModuleState) protocol.Reply {
+	v := ms.Values()
+	reply.Data["progress"] = int(burn.Progress() * 100)
+	reply.Data["commanded"] = burn.Plan().Commanded.Scale(100) // cm/s, same unit as the arguments
+	reply.Data["achieved"] = burn.Achieved.Scale(100)
+	reply.Data["propellant_kg"] = v.PropellantKg
+	reply.Data["velocity"] = v.Velocity
+	reply.Data["position"] = v.Position
+	reply.Data["throttle"] = ms.throttle()
+	return reply
+}
+
+func PerformThrust(cmd command.Command, thrust command.Vector, ms *ModuleState, ctx context.Context, tr transport.Transport) protocol.Reply {
+	logger.Plain("Performing thrust...")
+
+	logger.Plain(fmt.Sprintf("Starting Thrust: %s %+v", ms.machine.Current(), thrust))
+	if err := ms.setStatusFor(cmd.MSG_ID, fsm.ACTIVE, cmd.CMD); err != nil {
+		return protocol.Fail(cmd.MSG_ID, cmd.CMD, protocol.MODULE_NOT_IDLE, err.Error())
+	}
+
+	plan := ms.maneuver.Plan(cmPerS(thrust), ms.Values().PropellantKg)
+	burn := ms.maneuver.Start(plan)
+	logger.Plain(fmt.Sprintf("Burn plan: %.2fs, %.3f kg propellant, %.4f Wh", plan.Duration.Seconds(), plan.PropellantKg, plan.EnergyWh))
+
+	ticker := time.NewTicker(ManeuverStep)
+	defer ticker.Stop()
+	nextReport := 0.0
+	throttled := false
+
+	for !burn.Done() {
+
+		if ms.machine.Is(fsm.SAFE) {
+			// SAFE entered elsewhere (heartbeat loss ...), report the cause
+			// of the latch and leave it alone
+			reply := interrupted(cmd, "Thrust aborted, module SAFE", ms)
+			logger.Error("Thrust aborted: module SAFE (", reply.Reason, ")")
+			return maneuverData(reply, burn, ms)
+		}
+		if ms.Values().ThrustInhibit {
+			logger.Error("Thrust aborted: thrust inhibit asserted mid maneuver. Taking SAFE mode")
+			reply := abortToSafe(cmd, protocol.INHIBIT_ABORT, "Thrust aborted, module SAFE", ms)
+			return maneuverData(reply, burn, ms)
+		}
+		if ms.overtemp() {
+			logger.Error("Thrust aborted: overtemp. Taking SAFE mode")
+			reply := abortToSafe(cmd, protocol.OVERTEMP, "Thrust aborted, module SAFE", ms)
+			return maneuverData(reply, burn, ms)
+		}
+		if !ms._isSafe() {
+			logger.Warning("Thrust aborted: unsafe conditions detected.")
+			reply := abortToSafe(cmd, protocol.UNSAFE_CONDITIONS, "Thrust aborted", ms)
+			return maneuverData(reply, burn, ms)
+		}
+
+		if burn.Progress() >= nextReport {
+			progress := protocol.NewReply(cmd.MSG_ID, cmd.CMD, protocol.PROGRESS, "Thrust in progress")
+			logger.PubReply(ctx, tr, maneuverData(progress, burn, ms), ms.Snapshot(), "MODULE_Q")
+			nextReport += 0.2
+		}
+
+		select {
+		case <-ctx.Done():
+			// Aborted or timed out: cut thrust and return to IDLE
+			logger.Warning("Thrust stopped: ", context.Cause(ctx))
+			return maneuverData(stopped(ctx, cmd, ms, fsm.IDLE), burn, ms)
+		case <-ticker.C:
+		}
+
+		// Hot thrusters are throttled, the burn then falls short of the commanded delta-v
+		throttle := ms.throttle()
+		if throttle < 1 && !throttled {
+			logger.Warning(fmt.Sprintf("Thrust throttled to %.0f%%: temperature %.1f°C", throttle*100, ms.Values().Temperature))
+			throttled = true
+		}
+
+		dt := ManeuverStep.Seconds()
+		ms.Modify(func(v *Values) {
+			step := burn.Step(dt, ms.maneuver.DryMassKg+v.PropellantKg, throttle)
+			v.PropellantKg -= step.PropellantKg
+			v.Velocity = v.Velocity.Add(step.DeltaV)
+			v.Temperature += step.HeatC
+			v.BatteryLevel = ms.power.Drain(v.BatteryLevel, step.EnergyWh)
+		})
+
+	} // Thrust processing loop
+
+	logger.Info("Thrust complete.")
+	reply := protocol.NewReply(cmd.MSG_ID, cmd.CMD, protocol.RESULT, "Thrust Done")
+	if err := ms.setStatusFor(cmd.MSG_ID, fsm.IDLE, cmd.CMD); err != nil {
+		// SAFE got in after the last check of the loop
+		logger.Error("Thrust done, module not back to IDLE: ", err)
+		reply = interrupted(cmd, "Thrust done, module not back to IDLE", ms)
+	}
+	reply = maneuverData(reply, burn, ms)
+	reply.Data["duration_s"] = plan.Duration.Seconds()
+	return reply
+}
+
+// abortToSafe latches SAFE for reason and builds the ERROR of the burn
+func abortToSafe(cmd command.Command, reason protocol.Reason, message string, ms *ModuleState) protocol.Reply {
+	reply := protocol.Fail(cmd.MSG_ID, cmd.CMD, reason, message)
+	if err := ms.setStatusFor(cmd.MSG_ID, fsm.SAFE, string(reason)); err != nil {
+		reply.Message += ", entering SAFE failed: " + err.Error()
+	}
+	reply.Data["status"] = ms.machine.Current()
+	return reply
+}
@@ -0,0 +1,91 @@
+package state
+
+import (
+	"communication_module/command"
+	"communication_module/fsm"
+	"communication_module/protocol"
+	"encoding/json"
+	"testing"
+)
+
+// maneuver is a burn long enough to be stopped while it runs
+var maneuver = command.Command{MSG_ID: "m-1", CMD: "PERFORM_MANEUVER", ARGS: json.RawMessage(`{"x": 100, "y": 0, "z": 0}`)}
+
+func TestManeuverStopsOnOtherSafe(t *testing.T) {
+	ms := Initialize(DefaultConfig)
+	replyCh := start(t, ms, maneuver)
+	// What the main loop does on a lost host link
+	if err := ms.SetStatus(fsm.SAFE, string(protocol.HEARTBEAT_LOST)); err != nil {
+		t.Fatal(err)
+	}
+
+	reply := <-replyCh
+	if reply.Status != protocol.ERROR || reply.Reason != protocol.HEARTBEAT_LOST {
+		t.Fatalf("reply %s %s (%s), want ERROR HEARTBEAT_LOST", reply.Status, reply.Reason, reply.Message)
+	}
+	latch, ok := ms.Latch()
+	if !ok || latch.Cause != string(protocol.HEARTBEAT_LOST) {
+		t.Fatalf("latch %+v, want cause HEARTBEAT_LOST", latch)
+	}
+	if len(latch.Also) != 0 {
+		t.Fatalf("maneuver added causes %v to the latch", latch.Also)
+	}
+}
+
+func TestManeuverBudget(t *testing.T) {
+	tests := []struct {
+		name       string
+		propellant float64
+		battery    float64
+		reason     protocol.Reason
+	}{
+		{name: "propellant", propellant: 0.001, battery: 100, reason: protocol.INSUFFICIENT_PROPELLANT},
+		{name: "brownout", propellant: 5, battery: 25, reason: protocol.BROWNOUT},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ms := Initialize(DefaultConfig)
+			ms.Modify(func(v *Values) { v.PropellantKg, v.BatteryLevel = tt.propellant, tt.battery })
+
+			got := replies(t, ms, maneuver)
+			if len(got) != 1 || got[0].Status != protocol.REJECTED || got[0].Reason != tt.reason {
+				t.Fatalf("replies %+v, want a single REJECTED %s", got, tt.reason)
+			}
+			if v := ms.Values(); v.PropellantKg != tt.propellant || v.BatteryLevel != tt.battery {
+				t.Fatalf("rejected maneuver used propellant or battery: %+v", v)
+			}
+		})
+	}
+}
+
+func TestManeuverInhibited(t *testing.T) {
+	ms := Initialize(DefaultConfig)
+	ms.Modify(func(v *Values) { v.ThrustInhibit = true })
+	var transitions []fsm.Event
+	ms.OnTransition(func(ev fsm.Event) { transitions = append(transitions, ev) })
+
+	got := replies(t, ms, maneuver)
+	if len(got) != 1 || got[0].Status != protocol.REJECTED || got[0].Reason != protocol.THRUST_INHIBITED {
+		t.Fatalf("replies %+v, want a single REJECTED THRUST_INHIBITED", got)
+	}
+	if len(transitions) != 0 {
+		t.Fatalf("inhibited maneuver caused transitions %+v", transitions)
+	}
+}
+
+func TestManeuverInhibitMidBurn(t *testing.T) {
+	ms := Initialize(DefaultConfig)
+	replyCh := start(t, ms, maneuver)
+	ms.Modify(func(v *Values) { v.ThrustInhibit = true })
+
+	reply := <-replyCh
+	if reply.Status != protocol.ERROR || reply.Reason != protocol.INHIBIT_ABORT {
+		t.Fatalf("reply %s %s (%s), want ERROR INHIBIT_ABORT", reply.Status, reply.Reason, reply.Message)
+	}
+	if !ms.Is(fsm.SAFE) {
+		t.Fatalf("module %s, want SAFE", ms.Current())
+	}
+	if latch, ok := ms.Latch(); !ok || latch.Cause != string(protocol.INHIBIT_ABORT) {
+		t.Fatalf("latch %+v, want cause INHIBIT_ABORT", latch)
+	}
+}
@@ -0,0 +1,71 @@
+package state
+
+import (
+	"communication_module/command"
+	"communication_module/fsm"
+	"communication_module/logger"
+	"communication_module/protocol"
+	"communication_module/transport"
+	"context"
+	"errors"
+	"time"
+)
+
+func init() {
+	Register(Spec{
+		Name:        "RESUME",
+		Description: "Leave SAFE once the pre-checks pass",
+		Policy:      Policy{AllowedIn: AnyState, InSafe: "Spec SAFE command, the way out of SAFE", Exclusive: true, WhenBusy: REJECT_WHEN_BUSY},
+		Admit:       admitResume,
+		Timeout:     5 * time.Second,
+		Handler: func(cmd command.Command, args command.Args, ms *ModuleState, ctx context.Context, tr transport.Transport) protocol.Reply {
+			logger.Info("Resuming operations...")
+			return ResumePanel(cmd, ms, ctx, tr)
+		},
+	})
+}
+
+// admitResume runs the pre-checks before RESUME is ACCEPTED, outside SAFE
+// there is nothing to check
+func admitResume(cmd command.Command, args command.Args, ms *ModuleState) (protocol.Reply, bool) {
+	if !ms.machine.Is(fsm.SAFE) {
+		return protocol.Reply{}, true
+	}
+	if err := ms.preChecks(); err != nil {
+		logger.Warning("Cannot resume panel operations: unsafe conditions detected.")
+		return precheckReply(protocol.Reject(cmd.MSG_ID, cmd.CMD, protocol.PRECHECK_FAILED, err.Error()), err, ms), false
+	}
+	return protocol.Reply{}, true
+}
+
+// precheckReply adds the latch and the failed checks of err to reply
+func precheckReply(reply protocol.Reply, err error, ms *ModuleState) protocol.Reply {
+	latch, _ := ms.Latch()
+	reply.Data["latch"] = latch
+	var precheckErr *PrecheckError
+	if errors.As(err, &precheckErr) {
+		reply.Data["failed_checks"] = precheckErr.Failures
+	}
+	return reply
+}
+
+func ResumePanel(cmd command.Command, ms *ModuleState, ctx context.Context, tr transport.Transport) protocol.Reply {
+
+	// Logic to resume panel operations
+	if !ms.machine.Is(fsm.SAFE) {
+		reply := protocol.NewReply(cmd.MSG_ID, cmd.CMD, protocol.RESULT, "Module not in SAFE, nothing to resume")
+		reply.Data["status"] = ms.machine.Current()
+		return reply
+	}
+
+	latch, _ := ms.Latch()
+	if err := ms.setStatusFor(cmd.MSG_ID, fsm.IDLE, ResumeCause); err != nil {
+		// Passed the pre-checks when admitted, conditions changed since
+		logger.Warning("Cannot resume panel operations: unsafe conditions detected.")
+		return precheckReply(protocol.Fail(cmd.MSG_ID, cmd.CMD, protocol.PRECHECK_FAILED, err.Error()), err, ms)
+	}
+	reply := protocol.NewReply(cmd.MSG_ID, cmd.CMD, protocol.RESULT, "Resuming panel operations")
+	reply.Data["prechecks"] = ms.prechecks.Policy()
+	reply.Data["cleared_latch"] = latch
+	return reply
+}
@@ -0,0 +1,48 @@
+package state
+
+import (
+	"communication_module/command"
+	"communication_module/fsm"
+	"communication_module/protocol"
+	"testing"
+)
+
+func TestResumePrechecksBeforeAccepted(t *testing.T) {
+	tests := []struct {
+		name   string
+		cmd    string
+		temp   float64
+		want   []protocol.Status
+		reason protocol.Reason
+		state  fsm.State
+	}{
+		{name: "resume hot", cmd: "RESUME", temp: 70, want: []protocol.Status{protocol.REJECTED}, reason: protocol.PRECHECK_FAILED, state: fsm.SAFE},
+		{name: "resume nominal", cmd: "RESUME", temp: 20, want: []protocol.Status{protocol.ACCEPTED, protocol.RESULT}, state: fsm.IDLE},
+		{name: "heat and clear hot", cmd: "HEAT_AND_CLEAR", temp: 70, want: []protocol.Status{protocol.REJECTED}, reason: protocol.MODULE_SAFE, state: fsm.SAFE},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ms := Initialize(DefaultConfig)
+			if err := ms.SetStatus(fsm.SAFE, "test"); err != nil {
+				t.Fatal(err)
+			}
+			ms.Modify(func(v *Values) { v.Temperature = tt.temp })
+
+			got := replies(t, ms, command.Command{MSG_ID: "m-1", CMD: tt.cmd})
+			if len(got) != len(tt.want) {
+				t.Fatalf("replies %+v, want statuses %v", got, tt.want)
+			}
+			for i, r := range got {
+				if r.Status != tt.want[i] {
+					t.Fatalf("reply %d is %s, want %s (%s)", i, r.Status, tt.want[i], r.Message)
+				}
+			}
+			if last := got[len(got)-1]; last.Reason != tt.reason {
+				t.Fatalf("reason %q, want %q", last.Reason, tt.reason)
+			}
+			if current := ms.Current(); current != tt.state {
+				t.Fatalf("module %s, want %s", current, tt.state)
+			}
+		})
+	}
+}
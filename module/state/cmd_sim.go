@@ -0,0 +1,90 @@
+package state
+
+import (
+	"communication_module/command"
+	"communication_module/fault"
+	"communication_module/logger"
+	"communication_module/protocol"
+	"communication_module/transport"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Simulation controls. INJECT_FAULT and CLEAR_FAULT stay available in SAFE,
+// HEAT_AND_CLEAR does not: leaving SAFE takes a RESUME and its pre-checks.
+
+func init() {
+	Register(Spec{
+		Name:        "HEAT_AND_CLEAR",
+		Description: "Simulation: clear injected faults, restore nominal battery and temperature",
+		Policy:      Policy{AllowedIn: NotSafe},
+		Timeout:     5 * time.Second,
+		Handler: func(cmd command.Command, args command.Args, ms *ModuleState, ctx context.Context, tr transport.Transport) protocol.Reply {
+			logger.Info("Heating and Clearning module ...")
+			cleared := ms.faults.ClearAll()
+			ms.Modify(func(v *Values) { *v = nominal(*v) })
+			reply := protocol.NewReply(cmd.MSG_ID, cmd.CMD, protocol.RESULT, "Module heated and cleared")
+			reply.Data["cleared"] = cleared
+			return reply
+		},
+	})
+	Register(Spec{
+		Name:        "INJECT_FAULT",
+		Description: "Simulation: activate a named fault for duration_s (" + strings.Join(fault.Names(), ", ") + ")",
+		Args:        func() command.Args { return &command.FaultArgs{} },
+		Policy:      Policy{AllowedIn: AnyState, InSafe: "Simulation control, a test run injects and clears faults in SAFE too"},
+		Timeout:     5 * time.Second,
+		Handler: func(cmd command.Command, args command.Args, ms *ModuleState, ctx context.Context, tr transport.Transport) protocol.Reply {
+			return InjectFault(cmd, args.(*command.FaultArgs), ms)
+		},
+	})
+	Register(Spec{
+		Name:        "CLEAR_FAULT",
+		Description: "Simulation: clear an injected fault, all of them without a fault argument",
+		Args:        func() command.Args { return &command.ClearFaultArgs{} },
+		Policy:      Policy{AllowedIn: AnyState, InSafe: "Simulation control, a test run injects and clears faults in SAFE too"},
+		Timeout:     5 * time.Second,
+		Handler: func(cmd command.Command, args command.Args, ms *ModuleState, ctx context.Context, tr transport.Transport) protocol.Reply {
+			return ClearFault(cmd, args.(*command.ClearFaultArgs), ms)
+		},
+	})
+}
+
+// nominal is v with the battery and temperature HEAT_AND_CLEAR restores
+func nominal(v Values) Values {
+	v.BatteryLevel = 100
+	v.Temperature = 20.0
+	return v
+}
+
+func InjectFault(cmd command.Command, args *command.FaultArgs, ms *ModuleState) protocol.Reply {
+	kind := fault.Kind(*args.Fault)
+	duration := time.Duration(*args.DurationS * float64(time.Second))
+	f := ms.faults.Inject(kind, *args.Value, duration)
+	logger.Warning("Fault injected: ", f)
+
+	// Apply right away rather than on the next tick
+	ms.Modify(func(v *Values) { ms.applyFaults(v, 0) })
+
+	reply := protocol.NewReply(cmd.MSG_ID, cmd.CMD, protocol.RESULT, "Fault injected")
+	reply.Data["fault"] = f
+	reply.Data["active"] = ms.faults.Active()
+	return reply
+}
+
+func ClearFault(cmd command.Command, args *command.ClearFaultArgs, ms *ModuleState) protocol.Reply {
+	cleared := []fault.Kind{}
+	if args.Fault == nil {
+		cleared = ms.faults.ClearAll()
+	} else if ms.faults.Clear(fault.Kind(*args.Fault)) {
+		cleared = append(cleared, fault.Kind(*args.Fault))
+	}
+	logger.Info("Faults cleared: ", cleared)
+
+	reply := protocol.NewReply(cmd.MSG_ID, cmd.CMD, protocol.RESULT, fmt.Sprintf("%d fault(s) cleared", len(cleared)))
+	reply.Data["cleared"] = cleared
+	reply.Data["active"] = ms.faults.Active()
+	return reply
+}
@@ -0,0 +1,98 @@
+package state
+
+import (
+	"communication_module/command"
+	"communication_module/fault"
+	"communication_module/protocol"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+// active lists the kinds of the active faults of ms
+func active(ms *ModuleState) string {
+	var kinds []fault.Kind
+	for _, f := range ms.Faults().Active() {
+		kinds = append(kinds, f.Kind)
+	}
+	return fmt.Sprint(kinds)
+}
+
+func TestInjectFault(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   string
+		status protocol.Status
+		field  string  // Named by INVALID_ARGS
+		value  float64 // Of the injected fault
+	}{
+		{name: "brownout", args: `{"fault": "BROWNOUT", "value": 10, "duration_s": 60}`, status: protocol.RESULT, value: 10},
+		{name: "default value", args: `{"fault": "PAUSE_HEARTBEAT", "duration_s": 60}`, status: protocol.RESULT, value: fault.Kinds[fault.PAUSE_HEARTBEAT].Default},
+		{name: "unknown fault", args: `{"fault": "GREMLINS", "duration_s": 60}`, status: protocol.REJECTED, field: "fault"},
+		{name: "value out of range", args: `{"fault": "DROP_REPLIES", "value": 2, "duration_s": 60}`, status: protocol.REJECTED, field: "value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ms := Initialize(DefaultConfig)
+			got := replies(t, ms, command.Command{MSG_ID: "m-1", CMD: "INJECT_FAULT", ARGS: json.RawMessage(tt.args)})
+			last := got[len(got)-1]
+			if last.Status != tt.status {
+				t.Fatalf("reply %s %s (%s), want %s", last.Status, last.Reason, last.Message, tt.status)
+			}
+			if tt.status == protocol.REJECTED {
+				if last.Reason != protocol.INVALID_ARGS || last.Data["field"] != tt.field {
+					t.Fatalf("reply %s field %v, want INVALID_ARGS field %s", last.Reason, last.Data["field"], tt.field)
+				}
+				if faults := ms.Faults().Active(); len(faults) != 0 {
+					t.Fatalf("rejected INJECT_FAULT activated %v", faults)
+				}
+				return
+			}
+			faults := ms.Faults().Active()
+			if len(faults) != 1 || faults[0].Value != tt.value {
+				t.Fatalf("active %v, want one fault of value %v", faults, tt.value)
+			}
+		})
+	}
+}
+
+func TestInjectBrownoutAppliesNow(t *testing.T) {
+	ms := Initialize(DefaultConfig)
+	replies(t, ms, command.Command{MSG_ID: "m-1", CMD: "INJECT_FAULT", ARGS: json.RawMessage(`{"fault": "BROWNOUT", "value": 10, "duration_s": 60}`)})
+	if battery := ms.Values().BatteryLevel; battery != 10 {
+		t.Fatalf("battery %v%% right after BROWNOUT 10%%", battery)
+	}
+}
+
+func TestClearFault(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    string
+		cleared string
+		left    string
+	}{
+		{name: "one", args: `{"fault": "OVERTEMP"}`, cleared: "[OVERTEMP]", left: "[DROP_REPLIES]"},
+		{name: "not active", args: `{"fault": "BROWNOUT"}`, cleared: "[]", left: "[DROP_REPLIES OVERTEMP]"},
+		{name: "all", args: `{}`, cleared: "[DROP_REPLIES OVERTEMP]", left: "[]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ms := Initialize(DefaultConfig)
+			for _, args := range []string{`{"fault": "OVERTEMP", "value": 0, "duration_s": 60}`, `{"fault": "DROP_REPLIES", "value": 0, "duration_s": 60}`} {
+				replies(t, ms, command.Command{MSG_ID: "i-1", CMD: "INJECT_FAULT", ARGS: json.RawMessage(args)})
+			}
+
+			got := replies(t, ms, command.Command{MSG_ID: "m-1", CMD: "CLEAR_FAULT", ARGS: json.RawMessage(tt.args)})
+			last := got[len(got)-1]
+			if last.Status != protocol.RESULT {
+				t.Fatalf("reply %s %s (%s), want RESULT", last.Status, last.Reason, last.Message)
+			}
+			if cleared := fmt.Sprint(last.Data["cleared"]); cleared != tt.cleared {
+				t.Fatalf("cleared %s, want %s", cleared, tt.cleared)
+			}
+			if left := active(ms); left != tt.left {
+				t.Fatalf("active %s, want %s", left, tt.left)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package state
+
+import (
+	"communication_module/fault"
+	"communication_module/fsm"
+	"communication_module/logger"
+	"communication_module/protocol"
+	"communication_module/sim"
+	"fmt"
+	"time"
+)
+
+// Tick advances the simulated environment by dt: temperature, power and orbit.
+// It returns the reasons the module was forced into SAFE by this tick.
+func (ms *ModuleState) Tick(dt time.Duration) []protocol.Reason {
+	seconds := dt.Seconds()
+	ms.Modify(func(v *Values) {
+		v.Temperature = ms.thermal.Step(v.Temperature, seconds, sim.Loads{Camera: v.CameraOn})
+		v.HeaterOn = ms.thermal.Heater()
+
+		var power sim.PowerTelemetry
+		v.BatteryLevel, power = ms.power.Step(v.BatteryLevel, seconds, sim.PowerLoads{Camera: v.CameraOn, Heater: v.HeaterOn})
+		v.VoltageV, v.CurrentA, v.SolarW = power.VoltageV, power.CurrentA, power.SolarW
+
+		ms.applyFaults(v, seconds)
+		// Drift along the orbit with the delta-v of past maneuvers
+		v.Position = sim.Coast(v.Position, v.Velocity, seconds)
+	})
+
+	faults := []protocol.Reason{}
+	if ms.overtemp() && !ms.machine.Is(fsm.SAFE) {
+		logger.Error(fmt.Sprintf("Overtemp: %.1f°C, taking SAFE mode", ms.Values().Temperature))
+		if ms.SetStatus(fsm.SAFE, string(protocol.OVERTEMP)) == nil {
+			faults = append(faults, protocol.OVERTEMP)
+		}
+	}
+	if voltage := ms.voltage(); ms.power.Critical(voltage) && !ms.machine.Is(fsm.SAFE) {
+		logger.Error(fmt.Sprintf("Battery critical: %.2f V, taking SAFE mode", voltage))
+		if ms.SetStatus(fsm.SAFE, string(protocol.BATTERY_CRITICAL)) == nil {
+			faults = append(faults, protocol.BATTERY_CRITICAL)
+		}
+	}
+	return faults
+}
+
+// applyFaults applies the injected BROWNOUT and OVERTEMP faults for dt
+// seconds, called with mu held
+func (ms *ModuleState) applyFaults(v *Values, dt float64) {
+	if f, ok := ms.faults.Get(fault.BROWNOUT); ok && v.BatteryLevel > f.Value {
+		v.BatteryLevel = f.Value
+		v.VoltageV = ms.power.Voltage(v.BatteryLevel, v.CurrentA)
+	}
+	if f, ok := ms.faults.Get(fault.OVERTEMP); ok {
+		v.Temperature += f.Value * dt
+	}
+}
+
+// overtemp reports whether the temperature forces the module SAFE
+func (ms *ModuleState) overtemp() bool {
+	return ms.thermal.Overtemp(ms.Values().Temperature)
+}
+
+// throttle is the thrust fraction the current temperature allows
+func (ms *ModuleState) throttle() float64 {
+	return ms.thermal.Throttle(ms.Values().Temperature)
+}
+
+// voltage is the battery voltage at the current charge and load. Computed
+// rather than read from Values so a changed charge counts before the next tick.
+func (ms *ModuleState) voltage() float64 {
+	v := ms.Values()
+	return ms.power.Voltage(v.BatteryLevel, v.CurrentA)
+}
@@ -0,0 +1,76 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// BusyMode decides what happens to an exclusive command while another
+// exclusive command is running
+type BusyMode string
+
+const (
+	REJECT_WHEN_BUSY BusyMode = "REJECT" // Reply REJECTED: BUSY straight away
+	QUEUE_WHEN_BUSY  BusyMode = "QUEUE"  // Wait for the running command (until the context ends)
+)
+
+var ErrBusy = errors.New("another exclusive command is running")
+
+type queuedKey struct{}
+
+// WithQueued returns ctx with f, called when a command with ctx starts
+// waiting for the exclusive slot. The intake uses it to free the worker.
+func WithQueued(ctx context.Context, f func()) context.Context {
+	return context.WithValue(ctx, queuedKey{}, f)
+}
+
+// MaxQueued is how many commands may wait for the exclusive slot, the
+// next one is refused as BUSY. Every waiter holds an intake worker.
+var MaxQueued = 4
+
+// Executor makes sure only one exclusive command (maneuver, inspection ...)
+// runs at a time. Non exclusive commands such as HEALTH_CHECK bypass it.
+type Executor struct {
+	slot      chan string  // Holds the msg_id of the running exclusive command
+	queued    atomic.Int64 // Commands waiting for the slot
+	maxQueued int64
+}
+
+func NewExecutor() *Executor {
+	return &Executor{slot: make(chan string, 1), maxQueued: int64(MaxQueued)}
+}
+
+// Acquire claims the exclusive slot for msgID according to mode. The
+// returned release function must be called once the command finished. At
+// most MaxQueued commands wait, more get ErrBusy straight away. A queued
+// command whose context ends gets ErrBusy wrapping the cancel cause
+// (ErrAborted, context.DeadlineExceeded ...).
+func (e *Executor) Acquire(ctx context.Context, msgID string, mode BusyMode) (release func(), err error) {
+	release = func() { <-e.slot }
+
+	select {
+	case e.slot <- msgID:
+		return release, nil
+	default:
+	}
+
+	if mode != QUEUE_WHEN_BUSY {
+		return nil, ErrBusy
+	}
+	if n := e.queued.Add(1); n > e.maxQueued {
+		e.queued.Add(-1)
+		return nil, fmt.Errorf("%w: %d commands already queued", ErrBusy, e.maxQueued)
+	}
+	defer e.queued.Add(-1)
+	if queued, ok := ctx.Value(queuedKey{}).(func()); ok {
+		queued()
+	}
+	select {
+	case e.slot <- msgID:
+		return release, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%w: gave up waiting: %w", ErrBusy, context.Cause(ctx))
+	}
+}
@@ -0,0 +1,72 @@
+package state
+
+import (
+	"communication_module/command"
+	"communication_module/fsm"
+	"communication_module/protocol"
+	"communication_module/transport"
+	"context"
+	"testing"
+	"time"
+)
+
+// replies runs cmd and returns the replies published for it, in order
+func replies(t *testing.T, ms *ModuleState, cmd command.Command) []protocol.Reply {
+	t.Helper()
+	ctx := context.Background()
+	tr := transport.NewMemory(100)
+	sub, err := tr.Subscribe(ctx, "MODULE_Q")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+
+	ProcessCommand(cmd, ms, ctx, tr)
+
+	var got []protocol.Reply
+	for {
+		select {
+		case msg := <-sub.Messages():
+			r, err := protocol.UnmarshalReply([]byte(msg.Payload))
+			if err == nil && r.Type == protocol.REPLY && r.MsgID == cmd.MSG_ID {
+				got = append(got, r)
+			}
+		default:
+			return got
+		}
+	}
+}
+
+// start runs cmd and returns once the module is ACTIVE, the final reply
+// arrives on the channel
+func start(t *testing.T, ms *ModuleState, cmd command.Command) <-chan protocol.Reply {
+	t.Helper()
+	replyCh := make(chan protocol.Reply, 1)
+	go func() { replyCh <- runCommand(cmd, ms, context.Background(), transport.NewMemory(100)) }()
+
+	deadline := time.Now().Add(time.Second)
+	for !ms.Is(fsm.ACTIVE) {
+		if time.Now().After(deadline) {
+			t.Fatalf("%s never started", cmd.CMD)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return replyCh
+}
+
+// events returns the events published on sub so far
+func events(t *testing.T, sub transport.Subscription) []protocol.Event {
+	t.Helper()
+	var got []protocol.Event
+	for {
+		select {
+		case msg := <-sub.Messages():
+			e, err := protocol.UnmarshalEvent([]byte(msg.Payload))
+			if err == nil && e.Type == protocol.EVENT {
+				got = append(got, e)
+			}
+		default:
+			return got
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package state
+
+import (
+	"communication_module/fsm"
+	"communication_module/heartbeat"
+	"communication_module/logger"
+	"communication_module/protocol"
+	"communication_module/transport"
+	"context"
+	"fmt"
+)
+
+// HostLink acts on a check of the host heartbeat. Losing the link latches
+// SAFE (FAULT event). A restored link is only reported to the host
+// (HOST_LINK_RESTORED), SAFE stays latched until RESUME. A late host gets
+// one WARNING per missed beat, lastMissed is the count of the last check.
+func (ms *ModuleState) HostLink(ctx context.Context, tr transport.Transport, link heartbeat.Status, lastMissed int) {
+	switch {
+	case link.Lost && link.Changed:
+		logger.Error(
+			fmt.Sprintf("Host heartbeat lost: %d beats missed!", link.Missed),
+		)
+		// Set System state to FAULT
+		ms.SetStatus(fsm.SAFE, string(protocol.HEARTBEAT_LOST))
+		fault := protocol.NewEvent("FAULT", "")
+		fault.Reason = protocol.HEARTBEAT_LOST
+		fault.Data["missed_beats"] = link.Missed
+		fault.Data["host_link"] = link
+		logger.PubEvent(ctx, tr, fault, ms.Snapshot(), "MODULE_Q")
+
+	case !link.Lost && link.Changed:
+		// SAFE stays latched, only RESUME clears it. Just tell the host.
+		logger.Info("Host heartbeat restored.")
+		ms.Touch()
+		restored := protocol.NewEvent("HOST_LINK_RESTORED", "")
+		restored.Data["host_link"] = link
+		if latch, ok := ms.Latch(); ok {
+			logger.Info("Module stays SAFE (", latch.Cause, ") until RESUME.")
+			restored.Data["latch"] = latch
+		}
+		logger.PubEvent(ctx, tr, restored, ms.Snapshot(), "MODULE_Q")
+
+	case !link.Lost && link.Missed > 0 && link.Missed != lastMissed:
+		// Warning state, once per missed beat
+		ms.Touch()
+		logger.Info(
+			fmt.Sprintf("Host heartbeat late: %d beats missed.", link.Missed),
+		)
+		warning := protocol.NewEvent("WARNING", "")
+		warning.Data["missed_beats"] = link.Missed
+		warning.Data["host_link"] = link
+		logger.PubEvent(ctx, tr, warning, ms.Snapshot(), "MODULE_Q")
+	}
+}
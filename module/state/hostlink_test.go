@@ -0,0 +1,70 @@
+package state
+
+import (
+	"communication_module/command"
+	"communication_module/fsm"
+	"communication_module/heartbeat"
+	"communication_module/protocol"
+	"communication_module/transport"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestHostLinkRestoreKeepsLatch(t *testing.T) {
+	ctx := context.Background()
+	tr := transport.NewMemory(100)
+	sub, err := tr.Subscribe(ctx, "MODULE_Q")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+
+	ms := Initialize(DefaultConfig)
+	start := time.Now()
+	monitor := heartbeat.NewMonitor(heartbeat.DefaultConfig, start)
+	interval := heartbeat.DefaultConfig.Interval
+
+	// No beats for long enough: lost
+	now := start.Add(time.Duration(heartbeat.DefaultConfig.MissedLimit+2) * interval)
+	lost := monitor.Check(now)
+	ms.HostLink(ctx, tr, lost, 0)
+	if !ms.Is(fsm.SAFE) {
+		t.Fatalf("module %s after the link was lost, want SAFE", ms.Current())
+	}
+
+	// The host is back
+	monitor.Observe(heartbeat.Beat{Seq: 1, TS: now}, now)
+	restored := monitor.Check(now)
+	if restored.Lost || !restored.Changed {
+		t.Fatalf("link %+v, want restored", restored)
+	}
+	ms.HostLink(ctx, tr, restored, lost.Missed)
+
+	got := events(t, sub)
+	if len(got) != 2 || got[0].Message != "FAULT" || got[0].Reason != protocol.HEARTBEAT_LOST || got[1].Message != "HOST_LINK_RESTORED" {
+		t.Fatalf("events %+v, want FAULT HEARTBEAT_LOST then HOST_LINK_RESTORED", got)
+	}
+	if got[1].Data["latch"] == nil {
+		t.Fatal("HOST_LINK_RESTORED without the latch")
+	}
+	latch, ok := ms.Latch()
+	if !ms.Is(fsm.SAFE) || !ok || latch.Cause != string(protocol.HEARTBEAT_LOST) {
+		t.Fatalf("module %s latch %+v after restore, want SAFE latched on HEARTBEAT_LOST", ms.Current(), latch)
+	}
+
+	// Other commands don't clear it
+	replies(t, ms, command.Command{MSG_ID: "m-1", CMD: "HEALTH_CHECK"})
+	if latch, ok := ms.Latch(); !ms.Is(fsm.SAFE) || !ok || latch.Cause != string(protocol.HEARTBEAT_LOST) {
+		t.Fatalf("module %s latch %+v after HEALTH_CHECK, want still SAFE", ms.Current(), latch)
+	}
+
+	// RESUME does
+	resumed := replies(t, ms, command.Command{MSG_ID: "m-2", CMD: "RESUME"})
+	if last := resumed[len(resumed)-1]; last.Status != protocol.RESULT {
+		t.Fatalf("RESUME %s %s (%s), want RESULT", last.Status, last.Reason, last.Message)
+	}
+	if _, ok := ms.Latch(); !ms.Is(fsm.IDLE) || ok {
+		t.Fatalf("module %s latched %v after RESUME, want IDLE unlatched", ms.Current(), ok)
+	}
+}
@@ -0,0 +1,78 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrAborted is the cancel cause of a command stopped by ABORT / ABORT_ALL
+var ErrAborted = errors.New("aborted by host")
+
+type inFlightCmd struct {
+	cmd     string
+	started time.Time
+	cancel  context.CancelCauseFunc
+}
+
+// InFlight tracks the running (and queued) commands with their own
+// cancellable context so that they can be aborted by msg_id
+type InFlight struct {
+	mu   sync.Mutex
+	cmds map[string]inFlightCmd
+}
+
+func NewInFlight() *InFlight {
+	return &InFlight{cmds: map[string]inFlightCmd{}}
+}
+
+// Track derives the context of command msgID from ctx. The returned done
+// function must be called once the command finished. A msg_id already in
+// flight is refused (ok false), ABORT could not tell the two apart.
+func (f *InFlight) Track(ctx context.Context, msgID, cmd string) (_ context.Context, done func(), ok bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, running := f.cmds[msgID]; running {
+		return ctx, nil, false
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	f.cmds[msgID] = inFlightCmd{cmd: cmd, started: time.Now(), cancel: cancel}
+
+	return ctx, func() {
+		f.mu.Lock()
+		delete(f.cmds, msgID)
+		f.mu.Unlock()
+		cancel(nil)
+	}, true
+}
+
+// Abort cancels command msgID, false if no such command is in flight
+func (f *InFlight) Abort(msgID string) (string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, ok := f.cmds[msgID]
+	if !ok {
+		return "", false
+	}
+	c.cancel(ErrAborted)
+	return c.cmd, true
+}
+
+// AbortAll cancels every command in flight except `except` (the ABORT_ALL
+// itself) and returns the aborted msg_ids
+func (f *InFlight) AbortAll(except string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	aborted := []string{}
+	for msgID, c := range f.cmds {
+		if msgID == except {
+			continue
+		}
+		c.cancel(ErrAborted)
+		aborted = append(aborted, msgID)
+	}
+	sort.Strings(aborted)
+	return aborted
+}
@@ -0,0 +1,75 @@
+package state
+
+import (
+	"communication_module/command"
+	"communication_module/protocol"
+	"communication_module/transport"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestQueuedCommandStopped(t *testing.T) {
+	tests := []struct {
+		name   string
+		abort  bool
+		status protocol.Status
+		reason protocol.Reason
+	}{
+		{name: "aborted", abort: true, status: protocol.RESULT, reason: protocol.ABORTED},
+		{name: "gave up", abort: false, status: protocol.REJECTED, reason: protocol.BUSY},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ms := Initialize(DefaultConfig)
+			release, err := ms.executor.Acquire(context.Background(), "holder", REJECT_WHEN_BUSY)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer release()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+			cmd := command.Command{MSG_ID: "queued", CMD: "INSPECT_PANEL"}
+			replyCh := make(chan protocol.Reply, 1)
+			go func() { replyCh <- runCommand(cmd, ms, ctx, transport.NewMemory(10)) }()
+
+			if tt.abort {
+				deadline := time.Now().Add(time.Second)
+				for _, ok := ms.inflight.Abort(cmd.MSG_ID); !ok; _, ok = ms.inflight.Abort(cmd.MSG_ID) {
+					if time.Now().After(deadline) {
+						t.Fatal("command never queued")
+					}
+					time.Sleep(time.Millisecond)
+				}
+			}
+			reply := <-replyCh
+			if reply.Status != tt.status || reply.Reason != tt.reason {
+				t.Fatalf("reply %s %s (%s), want %s %s", reply.Status, reply.Reason, reply.Message, tt.status, tt.reason)
+			}
+			if !reply.IsFinal() {
+				t.Fatalf("reply %s is not final", reply.Status)
+			}
+		})
+	}
+}
+
+func TestInFlightRefusesDuplicate(t *testing.T) {
+	f := NewInFlight()
+	ctx, done, ok := f.Track(context.Background(), "m-1", "INSPECT_PANEL")
+	if !ok {
+		t.Fatal("first Track refused")
+	}
+	if _, _, ok := f.Track(context.Background(), "m-1", "INSPECT_PANEL"); ok {
+		t.Fatal("second Track of m-1 accepted")
+	}
+	if _, ok := f.Abort("m-1"); !ok || ctx.Err() == nil {
+		t.Fatal("first command not aborted")
+	}
+	done()
+	if _, done, ok := f.Track(context.Background(), "m-1", "INSPECT_PANEL"); !ok {
+		t.Fatal("Track after done refused")
+	} else {
+		done()
+	}
+}
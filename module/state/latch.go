@@ -0,0 +1,65 @@
+package state
+
+import (
+	"communication_module/fault"
+	"communication_module/fsm"
+	"errors"
+	"time"
+)
+
+// ResumeCause is the only cause that may take the module out of SAFE
+const ResumeCause = "RESUME"
+
+var ErrLatched = errors.New("SAFE is latched, only RESUME clears it")
+
+// SafeLatch records why the module is in SAFE. It is set when SAFE is
+// entered and stays until a RESUME passes its pre-checks.
+type SafeLatch struct {
+	Cause  string       `json:"cause"`            // Entry cause: HEARTBEAT_LOST, INHIBIT_ABORT, OVERTEMP ...
+	Since  time.Time    `json:"since"`            // Entry time
+	Also   []string     `json:"also,omitempty"`   // Later causes while latched
+	Faults []fault.Kind `json:"faults,omitempty"` // Injected faults active on entry
+}
+
+// Latch returns the SAFE latch, ok is false when the module is not latched
+func (ms *ModuleState) Latch() (SafeLatch, bool) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	if latch := ms.copyLatch(); latch != nil {
+		return *latch, true
+	}
+	return SafeLatch{}, false
+}
+
+// copyLatch returns a copy of the latch, nil if not latched. Called with mu held.
+func (ms *ModuleState) copyLatch() *SafeLatch {
+	if ms.latch == nil {
+		return nil
+	}
+	latch := *ms.latch
+	latch.Also = append([]string(nil), latch.Also...)
+	return &latch
+}
+
+// recordLatch updates the latch with transition ev. It is the commit of
+// the transition, so status and latch change together for GetSnapshot.
+func (ms *ModuleState) recordLatch(ev fsm.Event) {
+	var injected []fault.Kind
+	if ev.To == fsm.SAFE {
+		for _, f := range ms.faults.Active() {
+			injected = append(injected, f.Kind)
+		}
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	switch {
+	case ev.To != fsm.SAFE:
+		// SAFE -> IDLE, passed the RESUME guard
+		ms.latch = nil
+	case ms.latch == nil:
+		ms.latch = &SafeLatch{Cause: ev.Cause, Since: ev.Time, Faults: injected}
+	case ev.Cause != ms.latch.Cause:
+		ms.latch.Also = append(ms.latch.Also, ev.Cause)
+	}
+}
@@ -0,0 +1,99 @@
+package state
+
+import (
+	"communication_module/command"
+	"communication_module/fsm"
+	"communication_module/protocol"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Policy describes when a command may run.
+//
+// While latched in SAFE only HEALTH_CHECK, RESUME and SET_THRUST_INHIBIT
+// run. The exceptions are commands that neither start anything nor change
+// the state: HELP, CAPABILITIES and GET_HISTORY only read, ABORT and
+// ABORT_ALL only stop commands, INJECT_FAULT and CLEAR_FAULT are simulation
+// controls. Each says why in InSafe, which CAPABILITIES reports.
+type Policy struct {
+	AllowedIn []fsm.State // States the command may start in, nil means NotSafe
+	InSafe    string      // Why the command may run in SAFE, required if AllowedIn has SAFE
+	Exclusive bool        // Only one exclusive command runs at a time
+	WhenBusy  BusyMode    // What an exclusive command does while another runs
+}
+
+var (
+	// AnyState for commands that are safe to run while latched in SAFE
+	AnyState = []fsm.State{fsm.IDLE, fsm.ACTIVE, fsm.SAFE}
+	// NotSafe for commands that must be refused while latched in SAFE
+	NotSafe = []fsm.State{fsm.IDLE, fsm.ACTIVE}
+)
+
+func (p Policy) allowed() []fsm.State {
+	if p.AllowedIn == nil {
+		return NotSafe
+	}
+	return p.AllowedIn
+}
+
+// validate returns an error if p is incomplete
+func (p Policy) validate() error {
+	if slices.Contains(p.allowed(), fsm.SAFE) && p.InSafe == "" {
+		return errors.New("allowed in SAFE without saying why (InSafe)")
+	}
+	if p.Exclusive && p.WhenBusy != REJECT_WHEN_BUSY && p.WhenBusy != QUEUE_WHEN_BUSY {
+		return fmt.Errorf("exclusive with busy mode %q, want REJECT or QUEUE", p.WhenBusy)
+	}
+	return nil
+}
+
+// checkPolicy returns a REJECTED reply if cmd may not run in the current state
+func (ms *ModuleState) checkPolicy(msgID string, spec Spec) (protocol.Reply, bool) {
+	current := ms.machine.Current()
+	if slices.Contains(spec.Policy.allowed(), current) {
+		return protocol.Reply{}, true
+	}
+	if latch, ok := ms.Latch(); current == fsm.SAFE && ok {
+		reply := protocol.Reject(msgID, spec.Name, protocol.MODULE_SAFE,
+			fmt.Sprintf("%s not allowed while module is SAFE (%s), RESUME first", spec.Name, latch.Cause))
+		reply.Data["latch"] = latch
+		return reply, false
+	}
+	reason := protocol.INVALID_STATE
+	if current == fsm.SAFE {
+		reason = protocol.MODULE_SAFE
+	}
+	return protocol.Reject(msgID, spec.Name, reason,
+		fmt.Sprintf("%s not allowed while module is %s", spec.Name, current)), false
+}
+
+// admitIdle is the Admit check of commands that need the module IDLE
+func admitIdle(cmd command.Command, args command.Args, ms *ModuleState) (protocol.Reply, bool) {
+	if current := ms.machine.Current(); current != fsm.IDLE {
+		return protocol.Reject(cmd.MSG_ID, cmd.CMD, protocol.MODULE_NOT_IDLE, fmt.Sprintf("Module is %s", current)), false
+	}
+	return protocol.Reply{}, true
+}
+
+// acquire claims the executor for exclusive commands. A REJECTED: BUSY
+// reply is returned if the slot could not be had, the final RESULT:
+// ABORTED if the command was aborted while queued.
+func (ms *ModuleState) acquire(ctx context.Context, msgID string, spec Spec) (func(), protocol.Reply, bool) {
+	if !spec.Policy.Exclusive {
+		return func() {}, protocol.Reply{}, true
+	}
+	release, err := ms.executor.Acquire(ctx, msgID, spec.Policy.WhenBusy)
+	if errors.Is(err, ErrAborted) {
+		reply := protocol.NewReply(msgID, spec.Name, protocol.RESULT, "Command aborted while queued")
+		reply.Reason = protocol.ABORTED
+		reply.Data["outcome"] = "ABORTED"
+		reply.Data["cause"] = ErrAborted.Error()
+		return nil, reply, false
+	}
+	if err != nil {
+		return nil, protocol.Reject(msgID, spec.Name, protocol.BUSY, err.Error()), false
+	}
+	return release, protocol.Reply{}, true
+}
@@ -0,0 +1,67 @@
+package state
+
+import (
+	"communication_module/command"
+	"communication_module/fsm"
+	"communication_module/protocol"
+	"encoding/json"
+	"testing"
+)
+
+func TestPolicyInSafe(t *testing.T) {
+	tests := []struct {
+		cmd     command.Command
+		allowed bool
+	}{
+		{cmd: command.Command{CMD: "PERFORM_MANEUVER", ARGS: json.RawMessage(`{"x": 1, "y": 0, "z": 0}`)}},
+		{cmd: command.Command{CMD: "INSPECT_PANEL"}},
+		{cmd: command.Command{CMD: "HEAT_AND_CLEAR"}},
+		{cmd: command.Command{CMD: "HEALTH_CHECK"}, allowed: true},
+		{cmd: command.Command{CMD: "SET_THRUST_INHIBIT", ARGS: json.RawMessage(`{"inhibit": true}`)}, allowed: true},
+		{cmd: command.Command{CMD: "HELP"}, allowed: true},
+		{cmd: command.Command{CMD: "CLEAR_FAULT"}, allowed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cmd.CMD, func(t *testing.T) {
+			ms := Initialize(DefaultConfig)
+			if err := ms.SetStatus(fsm.SAFE, "OVERTEMP"); err != nil {
+				t.Fatal(err)
+			}
+			tt.cmd.MSG_ID = "m-1"
+			got := replies(t, ms, tt.cmd)
+			if len(got) == 0 {
+				t.Fatal("no reply")
+			}
+			first := got[0]
+			if tt.allowed {
+				if first.Status != protocol.ACCEPTED {
+					t.Fatalf("reply %s %s (%s), want ACCEPTED", first.Status, first.Reason, first.Message)
+				}
+				return
+			}
+			if len(got) != 1 || first.Status != protocol.REJECTED || first.Reason != protocol.MODULE_SAFE {
+				t.Fatalf("replies %+v, want a single REJECTED MODULE_SAFE", got)
+			}
+			latch, _ := first.Data["latch"].(map[string]interface{})
+			if latch["cause"] != "OVERTEMP" {
+				t.Fatalf("latch %v, want cause OVERTEMP", first.Data["latch"])
+			}
+			if !ms.Is(fsm.SAFE) {
+				t.Fatalf("module %s, want SAFE", ms.Current())
+			}
+		})
+	}
+}
+
+func TestPolicyInSafeSaysWhy(t *testing.T) {
+	for _, spec := range Commands() {
+		c := spec.Capability()
+		inSafe := false
+		for _, st := range c.AllowedStates {
+			inSafe = inSafe || st == string(fsm.SAFE)
+		}
+		if inSafe != (c.InSafe != "") {
+			t.Errorf("%s: allowed in SAFE %v, in_safe %q", c.Name, inSafe, c.InSafe)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package state
+
+import (
+	"communication_module/sim"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// PrecheckPolicy holds the limits the module has to be within to leave SAFE
+type PrecheckPolicy struct {
+	MinBatteryPct        float64 // Battery must be above this
+	MaxTempC             float64 // Temperature must be below this
+	BatteryHysteresisPct float64 // Extra margin needed once the battery check failed
+	TempHysteresisC      float64 // Extra margin needed once the temperature check failed
+}
+
+// DefaultPrechecks are the spec limits: battery > 20%, temp below the
+// overtemp limit (60 °C)
+var DefaultPrechecks = PrecheckPolicy{
+	MinBatteryPct:        20.0,
+	MaxTempC:             sim.DefaultThermal.SafeAboveC,
+	BatteryHysteresisPct: 2.0,
+	TempHysteresisC:      2.0,
+}
+
+// PrecheckError lists every check that failed
+type PrecheckError struct {
+	Failures []string
+}
+
+func (e *PrecheckError) Error() string {
+	return "pre-checks failed: " + strings.Join(e.Failures, ", ")
+}
+
+// Prechecker evaluates a PrecheckPolicy. Check is the RESUME check: a check
+// that failed keeps failing until the value clears its limit by the
+// hysteresis margin, so a value hovering on the limit does not flap between
+// pass and fail. Evaluate is the plain limits without that state.
+type Prechecker struct {
+	mu             sync.Mutex
+	policy         PrecheckPolicy
+	batteryTripped bool
+	tempTripped    bool
+}
+
+func NewPrechecker(policy PrecheckPolicy) *Prechecker {
+	return &Prechecker{policy: policy}
+}
+
+func (p *Prechecker) Policy() PrecheckPolicy {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.policy
+}
+
+// Evaluate returns nil or a *PrecheckError naming each failed check, it
+// does not change the hysteresis. For checks while running (e.g. a burn).
+func (p *Prechecker) Evaluate(v Values) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return precheckError(evaluate(v, p.policy.MinBatteryPct, p.policy.MaxTempC))
+}
+
+// Check is Evaluate with the hysteresis of the checks that failed last
+// time, and records which fail now. For leaving SAFE.
+func (p *Prechecker) Check(v Values) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	minBattery := p.policy.MinBatteryPct
+	if p.batteryTripped {
+		minBattery += p.policy.BatteryHysteresisPct
+	}
+	maxTemp := p.policy.MaxTempC
+	if p.tempTripped {
+		maxTemp -= p.policy.TempHysteresisC
+	}
+	failures := evaluate(v, minBattery, maxTemp)
+	p.batteryTripped = v.BatteryLevel <= minBattery
+	p.tempTripped = v.Temperature >= maxTemp
+	return precheckError(failures)
+}
+
+func evaluate(v Values, minBattery, maxTemp float64) []string {
+	failures := []string{}
+	if v.BatteryLevel <= minBattery {
+		failures = append(failures, fmt.Sprintf("battery %.1f%% <= %.1f%%", v.BatteryLevel, minBattery))
+	}
+	if v.Temperature >= maxTemp {
+		failures = append(failures, fmt.Sprintf("temp %.1f°C >= %.1f°C", v.Temperature, maxTemp))
+	}
+	return failures
+}
+
+func precheckError(failures []string) error {
+	if len(failures) > 0 {
+		return &PrecheckError{Failures: failures}
+	}
+	return nil
+}
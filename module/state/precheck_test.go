@@ -0,0 +1,105 @@
+package state
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestPrechecker(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []Values // Checked in order with Check
+		want  bool     // Last Check passes
+	}{
+		{name: "nominal", steps: []Values{{BatteryLevel: 80, Temperature: 20}}, want: true},
+		{name: "hot", steps: []Values{{BatteryLevel: 80, Temperature: 60}}, want: false},
+		{name: "low battery", steps: []Values{{BatteryLevel: 20, Temperature: 20}}, want: false},
+		{name: "battery inside hysteresis", steps: []Values{{BatteryLevel: 19, Temperature: 20}, {BatteryLevel: 21, Temperature: 20}}, want: false},
+		{name: "battery clears hysteresis", steps: []Values{{BatteryLevel: 19, Temperature: 20}, {BatteryLevel: 23, Temperature: 20}}, want: true},
+		{name: "temp inside hysteresis", steps: []Values{{BatteryLevel: 80, Temperature: 61}, {BatteryLevel: 80, Temperature: 59}}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPrechecker(DefaultPrechecks)
+			var err error
+			for _, v := range tt.steps {
+				err = p.Check(v)
+			}
+			if got := err == nil; got != tt.want {
+				t.Fatalf("Check passed = %v, want %v (%v)", got, tt.want, err)
+			}
+		})
+	}
+}
+
+func TestPrecheckerEvaluateKeepsHysteresis(t *testing.T) {
+	p := NewPrechecker(DefaultPrechecks)
+	// Evaluated on every burn step, a dip must not raise the RESUME limit
+	for _, battery := range []float64{19, 21, 19} {
+		p.Evaluate(Values{BatteryLevel: battery, Temperature: 20})
+	}
+	if err := p.Check(Values{BatteryLevel: 21, Temperature: 20}); err != nil {
+		t.Fatalf("Check after Evaluate: %v", err)
+	}
+	if err := p.Evaluate(Values{BatteryLevel: 21, Temperature: 60}); err == nil {
+		t.Fatal("Evaluate passed at the overtemp limit")
+	}
+}
+
+func TestPrecheckFailures(t *testing.T) {
+	tests := []struct {
+		name string
+		v    Values
+		want []string
+	}{
+		{name: "battery", v: Values{BatteryLevel: 15, Temperature: 20}, want: []string{"battery 15.0% <= 20.0%"}},
+		{name: "battery just below", v: Values{BatteryLevel: 19.6, Temperature: 20}, want: []string{"battery 19.6% <= 20.0%"}},
+		{name: "both", v: Values{BatteryLevel: 15, Temperature: 72}, want: []string{"battery 15.0% <= 20.0%", "temp 72.0°C >= 60.0°C"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewPrechecker(DefaultPrechecks).Evaluate(tt.v)
+			var precheckErr *PrecheckError
+			if !errors.As(err, &precheckErr) {
+				t.Fatalf("err %v, want a *PrecheckError", err)
+			}
+			if !slices.Equal(precheckErr.Failures, tt.want) {
+				t.Fatalf("failures %q, want %q", precheckErr.Failures, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrecheckBoundary(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []Values // Checked in order with Check
+		want  []string // Failures of the last Check, nil if it passes
+	}{
+		{name: "battery at the limit", steps: []Values{{BatteryLevel: 20, Temperature: 20}}, want: []string{"battery 20.0% <= 20.0%"}},
+		{name: "battery above the limit", steps: []Values{{BatteryLevel: 20.1, Temperature: 20}}},
+		{name: "temp at the limit", steps: []Values{{BatteryLevel: 80, Temperature: 60}}, want: []string{"temp 60.0°C >= 60.0°C"}},
+		{name: "battery at the hysteresis limit", steps: []Values{{BatteryLevel: 19, Temperature: 20}, {BatteryLevel: 22, Temperature: 20}}, want: []string{"battery 22.0% <= 22.0%"}},
+		{name: "battery above the hysteresis limit", steps: []Values{{BatteryLevel: 19, Temperature: 20}, {BatteryLevel: 22.1, Temperature: 20}}},
+		{name: "temp at the hysteresis limit", steps: []Values{{BatteryLevel: 80, Temperature: 61}, {BatteryLevel: 80, Temperature: 58}}, want: []string{"temp 58.0°C >= 58.0°C"}},
+		{name: "temp below the hysteresis limit", steps: []Values{{BatteryLevel: 80, Temperature: 61}, {BatteryLevel: 80, Temperature: 57.9}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPrechecker(DefaultPrechecks)
+			var err error
+			for _, v := range tt.steps {
+				err = p.Check(v)
+			}
+			var failures []string
+			var precheckErr *PrecheckError
+			if errors.As(err, &precheckErr) {
+				failures = precheckErr.Failures
+			}
+			if !slices.Equal(failures, tt.want) {
+				t.Fatalf("failures %q, want %q", failures, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package state
+
+import (
+	"communication_module/command"
+	"communication_module/protocol"
+	"communication_module/transport"
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Command registry
+//
+// Every command registers a Spec from an init() in its own file (see the
+// cmd_*.go files). Validation, dispatch and the HELP / CAPABILITIES output
+// all come from here, so adding a command never touches ProcessCommand.
+
+// Handler runs a command once it was ACCEPTED. It returns the final reply,
+// PROGRESS replies are published by the handler itself.
+type Handler func(cmd command.Command, args command.Args, ms *ModuleState, ctx context.Context, tr transport.Transport) protocol.Reply
+
+// Admit runs command specific checks before the command is ACCEPTED.
+// Returning false rejects the command with the returned reply.
+type Admit func(cmd command.Command, args command.Args, ms *ModuleState) (protocol.Reply, bool)
+
+// Spec describes a command
+type Spec struct {
+	Name        string
+	Description string
+	Args        func() command.Args // Returns an empty schema to decode into, nil for no arguments
+	Policy      Policy
+	Admit       Admit         // Optional extra checks before ACCEPTED
+	Timeout     time.Duration // Zero means DefaultTimeout
+	Handler     Handler
+}
+
+// DefaultTimeout of commands that don't set their own
+var DefaultTimeout = 10 * time.Second
+
+// TimeoutHeadroom is what the intake allows on top of the longest command
+// timeout, for the ACK, the dedup cache and the final reply
+var TimeoutHeadroom = 5 * time.Second
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Spec{}
+)
+
+// Register adds a command to the registry. It panics on a duplicate or
+// incomplete spec since that is a programming error.
+func Register(spec Spec) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if spec.Name == "" || spec.Handler == nil {
+		panic("state: Register needs a name and a handler")
+	}
+	if err := spec.Policy.validate(); err != nil {
+		panic(fmt.Sprintf("state: command %s: %v", spec.Name, err))
+	}
+	if _, dup := registry[spec.Name]; dup {
+		panic(fmt.Sprintf("state: command %s registered twice", spec.Name))
+	}
+	registry[spec.Name] = spec
+}
+
+// Lookup returns the spec of a registered command
+func Lookup(name string) (Spec, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	spec, ok := registry[name]
+	return spec, ok
+}
+
+// SetPolicy overrides the policy of a registered command
+func SetPolicy(name string, policy Policy) error {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	spec, ok := registry[name]
+	if !ok {
+		return fmt.Errorf("unknown command %s", name)
+	}
+	if err := policy.validate(); err != nil {
+		return fmt.Errorf("command %s: %w", name, err)
+	}
+	spec.Policy = policy
+	registry[name] = spec
+	return nil
+}
+
+// Commands returns all registered specs sorted by name
+func Commands() []Spec {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	specs := make([]Spec, 0, len(registry))
+	for _, spec := range registry {
+		specs = append(specs, spec)
+	}
+	sort.Slice(specs, func(i, j int) bool { return specs[i].Name < specs[j].Name })
+	return specs
+}
+
+// HandlerTimeout bounds the intake of one command: the longest timeout of
+// the registered commands plus TimeoutHeadroom
+func HandlerTimeout() time.Duration {
+	longest := DefaultTimeout
+	for _, spec := range Commands() {
+		longest = max(longest, spec.timeout())
+	}
+	return longest + TimeoutHeadroom
+}
+
+func (s Spec) timeout() time.Duration {
+	if s.Timeout <= 0 {
+		return DefaultTimeout
+	}
+	return s.Timeout
+}
+
+// parseArgs decodes and validates the arguments of cmd against the schema
+func (s Spec) parseArgs(cmd command.Command) (command.Args, error) {
+	var args command.Args = &command.NoArgs{}
+	if s.Args != nil {
+		args = s.Args()
+	}
+	if err := command.DecodeArgs(cmd.ARGS, args); err != nil {
+		return nil, err
+	}
+	if err := args.Validate(); err != nil {
+		return nil, err
+	}
+	return args, nil
+}
+
+// Capability is the description of a command reported to the host
+type Capability struct {
+	Name          string            `json:"name"`
+	Description   string            `json:"description"`
+	Args          map[string]string `json:"args,omitempty"`
+	AllowedStates []string          `json:"allowed_states"`
+	InSafe        string            `json:"in_safe,omitempty"` // Why it runs while latched in SAFE
+	Exclusive     bool              `json:"exclusive"`
+	WhenBusy      BusyMode          `json:"when_busy,omitempty"`
+	TimeoutMs     int64             `json:"timeout_ms"`
+}
+
+func (s Spec) Capability() Capability {
+	c := Capability{
+		Name:        s.Name,
+		Description: s.Description,
+		InSafe:      s.Policy.InSafe,
+		Exclusive:   s.Policy.Exclusive,
+		TimeoutMs:   s.timeout().Milliseconds(),
+	}
+	if s.Args != nil {
+		c.Args = command.Schema(s.Args())
+	}
+	for _, st := range s.Policy.allowed() {
+		c.AllowedStates = append(c.AllowedStates, string(st))
+	}
+	if s.Policy.Exclusive {
+		c.WhenBusy = s.Policy.WhenBusy
+	}
+	return c
+}
@@ -0,0 +1,86 @@
+package state
+
+import (
+	"communication_module/command"
+	"communication_module/fsm"
+	"communication_module/protocol"
+	"communication_module/transport"
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestInvalidArgsReply(t *testing.T) {
+	tests := []struct {
+		name  string
+		cmd   string
+		args  string
+		field string
+	}{
+		{name: "out of range", cmd: "PERFORM_MANEUVER", args: `{"x": 300, "y": 0, "z": 0}`, field: "x"},
+		{name: "missing", cmd: "PERFORM_MANEUVER", args: `{"x": 10, "y": 0}`, field: "z"},
+		{name: "unknown field", cmd: "SET_THRUST_INHIBIT", args: `{"inhibit": true, "force": true}`, field: "force"},
+		{name: "no arguments expected", cmd: "HEALTH_CHECK", args: `{"deep": true}`, field: "deep"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ms := Initialize(DefaultConfig)
+			got := replies(t, ms, command.Command{MSG_ID: "m-1", CMD: tt.cmd, ARGS: json.RawMessage(tt.args)})
+			if len(got) != 1 {
+				t.Fatalf("replies %+v, want a single REJECTED", got)
+			}
+			if r := got[0]; r.Status != protocol.REJECTED || r.Reason != protocol.INVALID_ARGS {
+				t.Fatalf("reply %s %s (%s), want REJECTED INVALID_ARGS", r.Status, r.Reason, r.Message)
+			}
+			if field := got[0].Data["field"]; field != tt.field {
+				t.Fatalf("reply names field %v, want %q", field, tt.field)
+			}
+			if current := ms.Current(); current != fsm.IDLE {
+				t.Fatalf("module %s after a rejected command, want IDLE", current)
+			}
+		})
+	}
+}
+
+func TestRegisterPanics(t *testing.T) {
+	handler := func(cmd command.Command, args command.Args, ms *ModuleState, ctx context.Context, tr transport.Transport) protocol.Reply {
+		return protocol.NewReply(cmd.MSG_ID, cmd.CMD, protocol.RESULT, "")
+	}
+	tests := []struct {
+		name string
+		spec Spec
+	}{
+		{name: "duplicate", spec: Spec{Name: "HEALTH_CHECK", Policy: Policy{AllowedIn: AnyState, InSafe: "test"}, Handler: handler}},
+		{name: "no name", spec: Spec{Handler: handler}},
+		{name: "no handler", spec: Spec{Name: "TEST_NO_HANDLER"}},
+		{name: "SAFE without why", spec: Spec{Name: "TEST_IN_SAFE", Policy: Policy{AllowedIn: AnyState}, Handler: handler}},
+		{name: "exclusive without busy mode", spec: Spec{Name: "TEST_EXCLUSIVE", Policy: Policy{Exclusive: true}, Handler: handler}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(Commands())
+			defer func() {
+				if recover() == nil {
+					t.Fatal("Register did not panic")
+				}
+				if after := len(Commands()); after != before {
+					t.Fatalf("%d commands registered after the panic, want %d", after, before)
+				}
+			}()
+			Register(tt.spec)
+		})
+	}
+}
+
+func TestSetPolicyValidates(t *testing.T) {
+	if err := SetPolicy("INSPECT_PANEL", Policy{Exclusive: true}); err == nil {
+		t.Fatal("SetPolicy accepted an exclusive policy without busy mode")
+	}
+	if err := SetPolicy("NOPE", Policy{}); err == nil {
+		t.Fatal("SetPolicy of an unknown command")
+	}
+	spec, _ := Lookup("INSPECT_PANEL")
+	if spec.Policy.WhenBusy != QUEUE_WHEN_BUSY {
+		t.Fatalf("policy %+v changed by a refused SetPolicy", spec.Policy)
+	}
+}
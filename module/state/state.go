@@ -2,18 +2,21 @@ package state
 
 import (
 	"communication_module/command"
+	"communication_module/fault"
+	"communication_module/fsm"
+	"communication_module/history"
 	"communication_module/logger"
+	"communication_module/protocol"
+	"communication_module/sim"
+	"communication_module/transport"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"reflect"
-
-	"github.com/google/uuid"
+	"sync"
 
 	"context"
-	"math/rand"
 	"time"
-
-	"github.com/redis/go-redis/v9"
 )
 
 // func StructToMap(s interface{}) (map[string]interface{}, error) {
@@ -40,52 +43,145 @@ func StructToMap(s interface{}) map[string]interface{} {
 	return m
 }
 
-// ModuleState represents the state of the module
+// Values are the plain fields of the module state. They are guarded by the
+// mutex of ModuleState and only handed out as copies.
+type Values struct {
+	LastCommand   command.Command
+	LastUpdated   int64   // Unix timestamp
+	BatteryLevel  float64 // Battery level percentage 0-100
+	Temperature   float64 // Temperature in Celsius
+	ThrustInhibit bool    // Set by the host, no thrust while asserted
+
+	PropellantKg float64        // Propellant left
+	Velocity     command.Vector // m/s, relative to the initial orbit
+	Position     command.Vector // m, relative to the initial orbit
+
+	CameraOn bool // Camera powered, heats the module
+	HeaterOn bool // Thermostat heater on
+
+	VoltageV float64 // Battery terminal voltage
+	CurrentA float64 // Battery current, positive when discharging
+	SolarW   float64 // Solar array output
+}
+
+// ModuleState represents the state of the module. It is shared by the main
+// loop and the command workers, all access goes through its methods.
 type ModuleState struct {
-	Status       string // e.g., "IDLE", "ACTIVE", "SAFE"
-	LastCommand  command.Command
-	LastUpdated  int64   // Unix timestamp
-	BatteryLevel int64   // Battery level percentage 0-100
-	Temperature  float64 // Temperature in Celsius
-	//LastCommandReturn map[string]interface{} // To store the result of the last command
+	mu        sync.RWMutex
+	values    Values
+	machine   *fsm.Machine // IDLE, ACTIVE or SAFE, only changed through transitions (has its own lock)
+	executor  *Executor    // Serializes exclusive commands
+	inflight  *InFlight    // Cancellable contexts of running commands
+	prechecks *Prechecker  // Limits to leave SAFE
+	maneuver  sim.ManeuverModel
+	thermal   *sim.Thermal // Used with mu held
+	power     *sim.Power   // Used with mu held
+	faults    *fault.Injector
+	latch     *SafeLatch   // Why the module is in SAFE, nil otherwise
+	history   *history.Log // Read by GET_HISTORY, nil without history
+}
+
+// Snapshot is a consistent copy of the module state for publishing
+type Snapshot struct {
+	Status fsm.State
+	Values
+	Faults []fault.Fault // Active injected faults
+	Latch  *SafeLatch    `json:",omitempty"`
 }
 
 // Getters
-func (ms *ModuleState) GetStatus() string {
-	// Make sure module is initialized
-	if ms.Status == "" {
-		ms.Status = "IDLE"
-	}
-	logger.Plain("Module status requested:", ms.Status)
-	return ms.Status
+// Current is the state of the machine, it only changes through SetStatus
+func (ms *ModuleState) Current() fsm.State {
+	return ms.machine.Current()
 }
 
-func (ms *ModuleState) GetandRedisLogStatus(ctx context.Context, rdb *redis.Client) string {
-	// Make sure module is initialized
-	if ms.Status == "" {
-		ms.Status = "IDLE"
-	}
-	logger.Plain("Module status requested:", ms.Status)
-	logger.PubModuleQ(ctx, rdb, "Status requested", StructToMap(ms), "MODULE_Q", map[string]interface{}{})
-	return ms.Status
+// Is reports whether the machine is in s
+func (ms *ModuleState) Is(s fsm.State) bool {
+	return ms.machine.Is(s)
+}
+
+// OnTransition calls f after every transition of the machine
+func (ms *ModuleState) OnTransition(f func(fsm.Event)) {
+	ms.machine.OnTransition(f)
+}
+
+func (ms *ModuleState) GetStatus() fsm.State {
+	status := ms.machine.Current()
+	logger.Plain("Module status requested:", status)
+	return status
+}
+
+func (ms *ModuleState) GetandRedisLogStatus(ctx context.Context, tr transport.Transport) fsm.State {
+	status := ms.machine.Current()
+	logger.Plain("Module status requested:", status)
+	logger.PubEvent(ctx, tr, protocol.NewEvent("Status requested", ""), ms.Snapshot(), "MODULE_Q")
+	return status
+}
+
+// Values returns a copy of the plain fields
+func (ms *ModuleState) Values() Values {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	return ms.values
+}
+
+// GetSnapshot returns the status, a copy of the plain fields, the active
+// faults and the latch, all read at one instant. The machine stays locked
+// meanwhile and the latch changes with the transition, so status and latch
+// always agree.
+func (ms *ModuleState) GetSnapshot() Snapshot {
+	var snapshot Snapshot
+	// Lock order: machine, then mu (the guards read the values)
+	ms.machine.View(func(status fsm.State) {
+		ms.mu.RLock()
+		defer ms.mu.RUnlock()
+		snapshot = Snapshot{Status: status, Values: ms.values, Faults: ms.faults.Active(), Latch: ms.copyLatch()}
+	})
+	return snapshot
+}
+
+// Snapshot returns the module state as a map for publishing to MODULE_Q
+func (ms *ModuleState) Snapshot() map[string]interface{} {
+	return StructToMap(ms.GetSnapshot())
 }
 
 // Setters
-func (ms *ModuleState) SetStatus(NewStatus string) {
-	// Do state machine checks here
-	// eg. module can't go from SAFE to ACTIVE directly
-	if ms.Status == "SAFE" && NewStatus == "ACTIVE" {
-		logger.Warning("Cannot set status to ACTIVE from SAFE. Has to IDLE first.")
-	}
-	if ms.Status == "SAFE" && NewStatus == "IDLE" {
-		logger.Info("Returning from Safe Mode")
-		ms.Status = "IDLE"
+// Modify runs fn with the write lock held, fn must not call back into ms
+func (ms *ModuleState) Modify(fn func(v *Values)) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	fn(&ms.values)
+	ms.values.LastUpdated = time.Now().Unix()
+}
+
+// Touch stamps LastUpdated
+func (ms *ModuleState) Touch() {
+	ms.Modify(func(v *Values) {})
+}
+
+// SetStatus moves the module to NewStatus through the state machine,
+// illegal or guarded transitions return an error. Entering SAFE latches
+// cause, see SafeLatch.
+func (ms *ModuleState) SetStatus(NewStatus fsm.State, cause string) error {
+	return ms.setStatusFor("", NewStatus, cause)
+}
+
+// setStatusFor is SetStatus by the command msgID, the transition event
+// carries it
+func (ms *ModuleState) setStatusFor(msgID string, NewStatus fsm.State, cause string) error {
+	if err := ms.machine.TransitionWith(msgID, NewStatus, cause, ms.recordLatch); err != nil {
+		logger.Warning("State transition failed: ", err)
+		return err
 	}
+	ms.Touch()
+	return nil
 }
 
 // Generic setter using reflection
 func (ms *ModuleState) SetField(field string, value interface{}) error {
-	v := reflect.ValueOf(ms).Elem()
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	v := reflect.ValueOf(&ms.values).Elem()
 	f := v.FieldByName(field)
 	if !f.IsValid() {
 		return fmt.Errorf("no such field: %s", field)
@@ -101,208 +197,185 @@ func (ms *ModuleState) SetField(field string, value interface{}) error {
 	return nil
 }
 
+// FaultSeed seeds the random decisions of injected faults (DROP_REPLIES)
+var FaultSeed int64 = 1
+
+// Faults returns the fault injector, for the parts of the module outside
+// the state (heartbeat check, reply publisher) that apply faults
+func (ms *ModuleState) Faults() *fault.Injector {
+	return ms.faults
+}
+
+// Config of the simulated module
+type Config struct {
+	Thermal   sim.ThermalModel
+	Power     sim.PowerModel
+	Prechecks PrecheckPolicy
+}
+
+// DefaultConfig is the module of the spec
+var DefaultConfig = Config{
+	Thermal:   sim.DefaultThermal,
+	Power:     sim.DefaultPower,
+	Prechecks: DefaultPrechecks,
+}
+
+// SetHistory is the history GET_HISTORY reads, the one the module records to
+func (ms *ModuleState) SetHistory(h *history.Log) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.history = h
+}
+
+// History returns the module history, nil if there is none
+func (ms *ModuleState) History() *history.Log {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	return ms.history
+}
+
 // Initialize the module state
-func Initialize() *ModuleState {
+func Initialize(cfg Config) *ModuleState {
 	logger.Info("Module state Initialized:")
-	return &ModuleState{
-		Status:      "IDLE",
-		LastUpdated: time.Now().Unix(),
-		LastCommand: command.Command{},
-		//LastCommandReturn: nil,
-		BatteryLevel: 100,
-		Temperature:  75.0,
+	ms := &ModuleState{
+		machine:   fsm.NewMachine(fsm.IDLE),
+		executor:  NewExecutor(),
+		inflight:  NewInFlight(),
+		prechecks: NewPrechecker(cfg.Prechecks),
+		maneuver:  sim.DefaultManeuver,
+		thermal:   sim.NewThermal(cfg.Thermal),
+		power:     sim.NewPower(cfg.Power),
+		faults:    fault.NewInjector(FaultSeed),
+		values: Values{
+			LastUpdated: time.Now().Unix(),
+			LastCommand: command.Command{},
+			//LastCommandReturn: nil,
+			BatteryLevel: 100,
+			Temperature:  20.0,
+			PropellantKg: 5.0,
+			VoltageV:     cfg.Power.OpenCircuitV(100),
+		},
 	}
+
+	// Guards of the transition table
+	ms.machine.SetGuard(fsm.SAFE, fsm.IDLE, func(ev fsm.Event) error {
+		// SAFE is latched: leaving it requires a RESUME and the pre-checks to pass
+		if ev.Cause != ResumeCause {
+			return ErrLatched
+		}
+		return ms.preChecks()
+	})
+	ms.machine.SetGuard(fsm.IDLE, fsm.ACTIVE, func(ev fsm.Event) error {
+		if ms.Values().BatteryLevel <= 0 {
+			return errors.New("battery depleted")
+		}
+		return nil
+	})
+	return ms
 }
 
 // update state based on a command
 func (ms *ModuleState) Update(cmd command.Command) {
 	// TODO: Think a lot about this, should be able to centralize updates to host
-	ms.LastCommand = cmd
-	ms.LastUpdated = time.Now().Unix()
-	logger.Info("Module state updated:", ms)
+	ms.Modify(func(v *Values) {
+		v.LastCommand = cmd
+	})
+	logger.Info("Module state updated:", ms.GetSnapshot())
 }
 
-func HealthCheck(ms *ModuleState, ctx context.Context, rdb *redis.Client) {
-	logger.Plain("Performing health check...")
-	return_payload := []string{}
-	return_map := map[string]interface{}{}
-
-	logger.Plain(fmt.Sprintf("Starting Health Check: %s", ms.Status))
-	logger.PubModuleQ(ctx, rdb, "Health check in progress", StructToMap(ms), "MODULE_Q", map[string]interface{}{})
-	return_payload = append(return_payload, fmt.Sprintf("BatteryLevel = %d", ms.BatteryLevel))
-	return_payload = append(return_payload, fmt.Sprintf("Temperature = %f", ms.Temperature))
-
-	return_map["type"] = "RET_VALUE"
-	return_map["return_params"] = return_payload
-
-	logger.Plain("Sending output of HEALTH_CHECK to MODULE_Q")
-	logger.PubModuleQ(ctx, rdb, "Health check completed", StructToMap(ms), "MODULE_Q", return_map)
-
-	ms.Status = "IDLE"
-
+// preChecks must pass before the module may leave SAFE
+func (ms *ModuleState) preChecks() error {
+	return ms.prechecks.Check(ms.Values())
 }
 
+// _isSafe is polled while running, it leaves the RESUME hysteresis alone
 func (ms *ModuleState) _isSafe() bool {
-	if ms.BatteryLevel < 20 {
-		return false
-	}
-	if ms.Temperature <= 60.0 {
+	if ms.prechecks.Evaluate(ms.Values()) != nil {
 		return false
 	}
-	if ms.Status == "SAFE" {
+	if ms.machine.Is(fsm.SAFE) {
 		return false
 	}
 	return true
 }
 
-func PerformThrust(ms *ModuleState, ctx context.Context, rdb *redis.Client) {
-	logger.Plain("Performing thrust...")
-	return_payload := []string{}
-	return_map := map[string]interface{}{}
-
-	logger.Plain(fmt.Sprintf("Starting Thrust: %s", ms.Status))
-	ms.Status = "ACTIVE"
-	return_map["type"] = "RET_VALUE"
-
-	for i := 0; i <= 100; i++ {
-
-		fmt.Printf("Processing step %d...\n", i)
-		if !ms._isSafe() {
-			logger.Warning("Thrust aborted: unsafe conditions detected.")
-			return_payload = append(return_payload, "THRUST ABORTED")
-			return_map["return_params"] = return_payload
-			logger.PubModuleQ(ctx, rdb, "Thrust aborted", StructToMap(ms), "MODULE_Q", return_map)
-			return
-		}
-
-		if i%20 == 0 {
-			return_payload = append(return_payload, fmt.Sprintf("Thrust in prog: %d%%", i))
-			return_map["return_params"] = return_payload
-			logger.PubModuleQ(ctx, rdb, "Thrust in progress", StructToMap(ms), "MODULE_Q", return_map)
-		}
-		time.Sleep(50 * time.Millisecond) // Sleep for 500ms to simulate work
-
-	} // Thrust processing loop
-
-	return_payload = append(return_payload, "Thrust Complete")
-	return_map["return_params"] = return_payload
-	logger.PubModuleQ(ctx, rdb, "Thrust Done", StructToMap(ms), "MODULE_Q", return_map)
-	fmt.Println("Processing complete!")
+// ProcessCommand runs cmd, publishes its final reply and returns it
+func ProcessCommand(cmd command.Command, ms *ModuleState, ctx context.Context, tr transport.Transport) protocol.Reply {
+	// Core of the module's state management aka state machine
+	logger.Info("Recieved Command: ", cmd.CMD)
 
-	ms.Status = "IDLE"
+	reply := runCommand(cmd, ms, ctx, tr)
 
+	// A command that ran out of time or was stopped at shutdown still owes
+	// the host its final reply, ctx may be gone by now
+	pubCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), FinalReplyTimeout)
+	defer cancel()
+	logger.PubReply(pubCtx, tr, reply, ms.Snapshot(), "MODULE_Q")
+	return reply
 }
 
-func InspectPanel(ms *ModuleState, ctx context.Context, rdb *redis.Client) {
+// FinalReplyTimeout bounds the publish of the final reply of a command
+var FinalReplyTimeout = 5 * time.Second
 
-	return_payload := []string{}
-	return_map := map[string]interface{}{}
-	return_map["type"] = "RET_VALUE"
-
-	// Logic to inspect the panel
-	// logger.PubModuleQ(ctx, rdb, "", ms_state_repr, "MODULE_Q")
-	//logger.PubModuleQ(ctx, rdb, "Panel inspection started", map[string]interface{}{}, "MODULE_Q")
-
-	//st, _ := StructToMap(ms)
-	logger.Plain(fmt.Sprintf("Starting Panel Inspection: %s", ms.Status))
-
-	if ms.Status != "IDLE" {
-		logger.Warning(fmt.Sprintf("Cannot inspect panel while module is not IDLE. Current status: %s", ms.Status))
-		return_payload = append(return_payload, "INS ABORTED: Mod not IDLE")
-		return_map["return_params"] = return_payload
-		logger.PubModuleQ(ctx, rdb, "Panel inspection started", StructToMap(ms), "MODULE_Q", return_map)
-		return
+// runCommand looks cmd up in the registry, runs the policy and argument
+// checks and dispatches it to its handler
+func runCommand(cmd command.Command, ms *ModuleState, ctx context.Context, tr transport.Transport) protocol.Reply {
+	spec, known := Lookup(cmd.CMD)
+	if !known {
+		logger.Error("Unknown command:", cmd.CMD)
+		return protocol.Fail(cmd.MSG_ID, cmd.CMD, protocol.UNRECOGNIZED_COMMAND, fmt.Sprintf("Unknown command %q", cmd.CMD))
 	}
 
-	//return_payload = append(return_payload, "Panel Inspection Starting")
-	//return_map["return_params"] = return_payload
-	//logger.PubModuleQ(ctx, rdb, "Panel inspection started", StructToMap(ms), "MODULE_Q", return_map)
-	ms.Status = "ACTIVE"
-
-	//return_payload = append(return_payload, "Taking Photo")
-	//return_map["return_params"] = return_payload
-	//logger.PubModuleQ(ctx, rdb, "Taking Photgraph of Panel", StructToMap(ms), "MODULE_Q", return_map)
-	n := rand.Intn(2000-200+1) + 200                // Random time to do this between 200ms and 2s
-	time.Sleep(time.Duration(n) * time.Millisecond) // Simulate time taken to take a photo
-
-	return_payload = append(return_payload, "OK")
-	return_payload = append(return_payload, "image_captured")
-	return_payload = append(return_payload, fmt.Sprintf("uri://%s", uuid.New().String()))
-
-	return_map["type"] = "RET_VALUE"
-	return_map["return_params"] = return_payload
+	// Commands not allowed in the current state (e.g. non-safe commands in
+	// SAFE) are refused before anything else happens
+	if rejected, ok := ms.checkPolicy(cmd.MSG_ID, spec); !ok {
+		logger.Warning("Rejecting ", cmd.CMD, ": ", rejected.Message)
+		return rejected
+	}
 
-	logger.Plain("Sending output of INSPECT_PANEL to MODULE_Q")
-	logger.PubModuleQ(ctx, rdb, "Photograph taken", StructToMap(ms), "MODULE_Q", return_map)
+	// Decode and validate the arguments before anything runs
+	args, err := spec.parseArgs(cmd)
+	if err != nil {
+		logger.Warning("Rejecting ", cmd.CMD, ": ", err)
+		reply := protocol.Reject(cmd.MSG_ID, cmd.CMD, protocol.INVALID_ARGS, err.Error())
+		var argErr *command.ArgError
+		if errors.As(err, &argErr) {
+			reply.Data["field"] = argErr.Field
+		}
+		return reply
+	}
 
-	ms.Status = "IDLE"
-}
+	// Own cancellable context so ABORT can stop the command (queued or running)
+	ctx, done, ok := ms.inflight.Track(ctx, cmd.MSG_ID, cmd.CMD)
+	if !ok {
+		logger.Warning("Rejecting ", cmd.CMD, ": ", cmd.MSG_ID, " already in flight")
+		return protocol.Reject(cmd.MSG_ID, cmd.CMD, protocol.ALREADY_IN_FLIGHT, fmt.Sprintf("Command %s is already in flight", cmd.MSG_ID))
+	}
+	defer done()
+	ctx, cancel := context.WithTimeout(ctx, spec.timeout())
+	defer cancel()
+
+	// Only one exclusive command at a time, queue or reject as configured
+	release, busy, ok := ms.acquire(ctx, cmd.MSG_ID, spec)
+	if !ok {
+		logger.Warning("Not running ", cmd.CMD, ": ", busy.Message)
+		return busy
+	}
+	defer release()
 
-func ResumePanel(ms *ModuleState, ctx context.Context, rdb *redis.Client) {
-	return_payload := []string{}
-	return_map := map[string]interface{}{}
-
-	return_payload = append(return_payload, "Preparing to resume")
-
-	// Logic to resume panel operations
-	if ms._isSafe() {
-		return_map["type"] = "RET_VALUE"
-		return_payload = append(return_payload, "Safe to resume")
-		return_map["return_params"] = return_payload
-
-		logger.PubModuleQ(ctx, rdb, "Resuming panel operations", StructToMap(ms), "MODULE_Q", return_map)
-		ms.Status = "IDLE"
-	} else {
-		logger.Warning("Cannot resume panel operations: unsafe conditions detected.")
-		return_map["type"] = "RET_VALUE"
-		return_payload = append(return_payload, "Unsafe to resume")
-		return_map["return_params"] = return_payload
-		logger.PubModuleQ(ctx, rdb, "Can not resume panel operations", StructToMap(ms), "MODULE_Q", return_map)
+	if spec.Admit != nil {
+		if rejected, ok := spec.Admit(cmd, args, ms); !ok {
+			logger.Warning("Rejecting ", cmd.CMD, ": ", rejected.Message)
+			return rejected
+		}
 	}
-}
 
-func ProcessCommand(cmd command.Command, ms *ModuleState, ctx context.Context, rdb *redis.Client) {
-	// Core of the module's state management aka state machine
-	logger.Info("Recieved Command: ", cmd.CMD)
+	// All checks passed, from here on the host gets PROGRESS and a RESULT.
+	// Only now is it the module's last command.
+	ms.Update(cmd)
+	accepted := protocol.NewReply(cmd.MSG_ID, cmd.CMD, protocol.ACCEPTED, "Command accepted")
+	accepted.Data["args"] = args
+	logger.PubReply(ctx, tr, accepted, ms.Snapshot(), "MODULE_Q")
 
-	switch cmd.CMD {
-	case "INSPECT_PANEL":
-		logger.Info("Inspecting panel")
-
-		// TODO: Think about this pattern if time permits
-		//ms.Update(command.Command{CMD: "INSPECT_PANEL_FAILED"})
-		//ms.Update(cmd)
-		//ms.Status = "ACTIVE"
-		InspectPanel(ms, ctx, rdb)
-
-		// Add logic to inspect panel
-	case "PERFORM_MANEUVER":
-		logger.Info("Activating thrust...")
-		PerformThrust(ms, ctx, rdb)
-		// Add logic to activate thrust
-	case "RESUME":
-		logger.Info("Resuming operations...")
-		// Add logic to resume operations
-		ResumePanel(ms, ctx, rdb)
-
-	case "HEALTH_CHECK":
-		logger.Info("Performing health check...")
-		HealthCheck(ms, ctx, rdb)
-
-	case "HEAT_AND_CLEAR":
-		logger.Info("Heating and Clearning module ...")
-		ms.BatteryLevel = 100
-		ms.Temperature = 80.0
-		ResumePanel(ms, ctx, rdb)
-
-	case "INJECT_FAULT":
-		logger.Info("Injecting fault into system...")
-		//InjectFault(ms, ctx, rdb)
-		ms.Temperature = 0.0
-		ms.BatteryLevel = 0
-		ms.Status = "SAFE"
-
-	default:
-		logger.Error("Unknown command:", cmd.CMD)
-	}
+	return spec.Handler(cmd, args, ms, ctx, tr)
 }
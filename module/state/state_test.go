@@ -0,0 +1,184 @@
+package state
+
+import (
+	"communication_module/command"
+	"communication_module/fsm"
+	"communication_module/protocol"
+	"communication_module/transport"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSafeToIdleGuard(t *testing.T) {
+	tests := []struct {
+		name   string
+		cause  string
+		temp   float64
+		err    error // nil when the module leaves SAFE
+		checks bool  // Refused by the pre-checks
+	}{
+		{name: "resume nominal", cause: ResumeCause, temp: 20},
+		{name: "resume hot", cause: ResumeCause, temp: 70, checks: true},
+		{name: "not a resume", cause: "HEALTH_CHECK", temp: 20, err: ErrLatched},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ms := Initialize(DefaultConfig)
+			if err := ms.SetStatus(fsm.SAFE, "test"); err != nil {
+				t.Fatal(err)
+			}
+			ms.Modify(func(v *Values) { v.Temperature = tt.temp })
+
+			err := ms.SetStatus(fsm.IDLE, tt.cause)
+			var precheckErr *PrecheckError
+			switch {
+			case tt.checks && !errors.As(err, &precheckErr):
+				t.Fatalf("err %v, want a *PrecheckError", err)
+			case tt.err != nil && !errors.Is(err, tt.err):
+				t.Fatalf("err %v, want %v", err, tt.err)
+			case !tt.checks && tt.err == nil && err != nil:
+				t.Fatalf("RESUME refused: %v", err)
+			}
+
+			left := err == nil
+			if ms.Is(fsm.SAFE) == left {
+				t.Fatalf("module %s, left SAFE %v", ms.Current(), left)
+			}
+			if _, latched := ms.Latch(); latched == left {
+				t.Fatalf("latched %v after leaving SAFE %v", latched, left)
+			}
+		})
+	}
+}
+
+func TestLastCommandOnlyWhenAccepted(t *testing.T) {
+	tests := []struct {
+		name     string
+		cmd      command.Command
+		safe     bool
+		accepted bool
+	}{
+		{name: "accepted", cmd: command.Command{MSG_ID: "m-1", CMD: "HEALTH_CHECK"}, accepted: true},
+		{name: "unknown", cmd: command.Command{MSG_ID: "m-1", CMD: "NOPE"}},
+		{name: "blocked in SAFE", cmd: command.Command{MSG_ID: "m-1", CMD: "INSPECT_PANEL"}, safe: true},
+		{name: "invalid args", cmd: command.Command{MSG_ID: "m-1", CMD: "PERFORM_MANEUVER", ARGS: json.RawMessage(`{"x": 300}`)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ms := Initialize(DefaultConfig)
+			if tt.safe {
+				if err := ms.SetStatus(fsm.SAFE, "test"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			replies(t, ms, tt.cmd)
+
+			last := ms.Values().LastCommand
+			if recorded := last.MSG_ID == tt.cmd.MSG_ID; recorded != tt.accepted {
+				t.Fatalf("last command %+v, recorded %v, want %v", last, recorded, tt.accepted)
+			}
+		})
+	}
+}
+
+// liveOnly refuses to publish on a context that ended, like a real link
+type liveOnly struct {
+	*transport.Memory
+}
+
+func (l liveOnly) Publish(ctx context.Context, channel, payload string) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return l.Memory.Publish(ctx, channel, payload)
+}
+
+func TestFinalReplyOutlivesIntake(t *testing.T) {
+	tr := liveOnly{transport.NewMemory(100)}
+	sub, err := tr.Subscribe(context.Background(), "MODULE_Q")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+
+	// The intake gives up before the inspection (at least 200ms) is done
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	ProcessCommand(command.Command{MSG_ID: "m-1", CMD: "INSPECT_PANEL"}, Initialize(DefaultConfig), ctx, tr)
+
+	for {
+		select {
+		case msg := <-sub.Messages():
+			r, err := protocol.UnmarshalReply([]byte(msg.Payload))
+			if err != nil || r.Type != protocol.REPLY || !r.IsFinal() {
+				continue
+			}
+			if r.Status != protocol.ERROR || r.Reason != protocol.TIMEOUT {
+				t.Fatalf("final reply %s %s, want ERROR TIMEOUT", r.Status, r.Reason)
+			}
+			return
+		default:
+			t.Fatal("final reply not published")
+		}
+	}
+}
+
+func TestHandlerTimeoutCoversCommands(t *testing.T) {
+	for _, spec := range Commands() {
+		if HandlerTimeout() <= spec.timeout() {
+			t.Fatalf("intake timeout %v does not cover %s (%v)", HandlerTimeout(), spec.Name, spec.timeout())
+		}
+	}
+}
+
+func TestInitializeConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(cfg *Config)
+		fault  protocol.Reason
+	}{
+		{name: "thermal", modify: func(cfg *Config) { cfg.Thermal.SafeAboveC = 25 }, fault: protocol.OVERTEMP},
+		{name: "power", modify: func(cfg *Config) { cfg.Power.CriticalV = cfg.Power.FullV }, fault: protocol.BATTERY_CRITICAL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig
+			cfg.Thermal.NoiseC = 0
+			tt.modify(&cfg)
+			ms := Initialize(cfg)
+			ms.Modify(func(v *Values) { v.Temperature = 30 })
+
+			faults := ms.Tick(time.Second)
+			if len(faults) != 1 || faults[0] != tt.fault || !ms.Is(fsm.SAFE) {
+				t.Fatalf("faults %v, module %s, want %s: the configured model was not used", faults, ms.Current(), tt.fault)
+			}
+		})
+	}
+}
+
+func TestSnapshotStatusAndLatchAgree(t *testing.T) {
+	ms := Initialize(DefaultConfig)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 500; i++ {
+			ms.SetStatus(fsm.ACTIVE, "test")
+			ms.SetStatus(fsm.SAFE, string(protocol.OVERTEMP))
+			ms.SetStatus(fsm.IDLE, ResumeCause)
+		}
+	}()
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		s := ms.GetSnapshot()
+		if (s.Status == fsm.SAFE) != (s.Latch != nil) {
+			t.Fatalf("snapshot %s with latch %+v", s.Status, s.Latch)
+		}
+	}
+}
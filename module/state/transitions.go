@@ -0,0 +1,23 @@
+package state
+
+import (
+	"communication_module/fsm"
+	"communication_module/logger"
+	"communication_module/protocol"
+	"communication_module/transport"
+	"context"
+)
+
+// PublishTransitions publishes every state transition to the host. Only a
+// transition made by a command is correlated with it, events of the module
+// itself (STATUS, WARNING, FAULT ...) have no correlation_id.
+func (ms *ModuleState) PublishTransitions(ctx context.Context, tr transport.Transport) {
+	ms.OnTransition(func(ev fsm.Event) {
+		logger.Info("State transition: ", ev.From, " -> ", ev.To, " (", ev.Cause, ")")
+		transition := protocol.NewEvent("TRANSITION", ev.MsgID)
+		transition.Data["from"] = ev.From
+		transition.Data["to"] = ev.To
+		transition.Data["cause"] = ev.Cause
+		logger.PubEvent(ctx, tr, transition, ms.Snapshot(), "MODULE_Q")
+	})
+}
@@ -0,0 +1,59 @@
+package state
+
+import (
+	"communication_module/command"
+	"communication_module/heartbeat"
+	"communication_module/transport"
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTransitionCorrelation(t *testing.T) {
+	ctx := context.Background()
+	tr := transport.NewMemory(100)
+	sub, err := tr.Subscribe(ctx, "MODULE_Q")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+
+	ms := Initialize(DefaultConfig)
+	ms.PublishTransitions(ctx, tr)
+
+	// Command: IDLE -> ACTIVE -> IDLE
+	ProcessCommand(command.Command{MSG_ID: "m-1", CMD: "PERFORM_MANEUVER", ARGS: json.RawMessage(`{"x": 1, "y": 0, "z": 0}`)}, ms, ctx, tr)
+	// Fault: IDLE -> SAFE
+	ms.Modify(func(v *Values) { v.Temperature = 200 })
+	ms.Tick(time.Millisecond)
+	// Command: SAFE -> IDLE
+	ms.Modify(func(v *Values) { v.Temperature = 20 })
+	ProcessCommand(command.Command{MSG_ID: "m-2", CMD: "RESUME"}, ms, ctx, tr)
+	// Watchdog: IDLE -> SAFE on a lost host link
+	monitor := heartbeat.NewMonitor(heartbeat.DefaultConfig, time.Now())
+	lost := monitor.Check(time.Now().Add(time.Duration(heartbeat.DefaultConfig.MissedLimit+2) * heartbeat.DefaultConfig.Interval))
+	ms.HostLink(ctx, tr, lost, 0)
+
+	got := events(t, sub)
+	seen := map[string]bool{}
+	var transitions []string
+	for _, e := range got {
+		if e.MsgID == "" || seen[e.MsgID] {
+			t.Fatalf("event %s with msg_id %q, want a unique one", e.Message, e.MsgID)
+		}
+		seen[e.MsgID] = true
+		if e.Message != "TRANSITION" {
+			if e.CorrelationID != "" {
+				t.Fatalf("%s event of the module correlated with %q", e.Message, e.CorrelationID)
+			}
+			continue
+		}
+		transitions = append(transitions, fmt.Sprintf("%v->%v:%s", e.Data["from"], e.Data["to"], e.CorrelationID))
+	}
+	want := []string{"IDLE->ACTIVE:m-1", "ACTIVE->IDLE:m-1", "IDLE->SAFE:", "SAFE->IDLE:m-2", "IDLE->SAFE:"}
+	if fmt.Sprint(transitions) != fmt.Sprint(want) {
+		t.Fatalf("transitions %v, want %v", transitions, want)
+	}
+}
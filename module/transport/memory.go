@@ -0,0 +1,251 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Memory is an in-process Transport for tests and single process demos.
+// Keys expire lazily on access. Like a slow Redis subscriber, a
+// subscription whose buffer is full misses messages.
+type Memory struct {
+	mu      sync.Mutex
+	values  map[string]memValue
+	lists   map[string][]string // Index 0 is the head (LPUSH end)
+	subs    map[*memSubscription]struct{}
+	streams map[string]*memStream
+	added   chan struct{} // Closed and replaced on every XAdd
+	bufSize int
+	closed  bool
+}
+
+type memValue struct {
+	value   string
+	expires time.Time // Zero never expires
+}
+
+var ErrClosed = errors.New("transport: closed")
+
+// NewMemory returns an empty transport, subscriptions buffer bufSize messages
+func NewMemory(bufSize int) *Memory {
+	if bufSize <= 0 {
+		bufSize = 1024
+	}
+	return &Memory{
+		values:  map[string]memValue{},
+		lists:   map[string][]string{},
+		subs:    map[*memSubscription]struct{}{},
+		streams: map[string]*memStream{},
+		added:   make(chan struct{}),
+		bufSize: bufSize,
+	}
+}
+
+func (m *Memory) Ping(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return ErrClosed
+	}
+	return nil
+}
+
+// Close ends every subscription
+func (m *Memory) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for sub := range m.subs {
+		sub.close()
+	}
+	m.subs = map[*memSubscription]struct{}{}
+	m.closed = true
+	return nil
+}
+
+type memSubscription struct {
+	m        *Memory
+	channels map[string]bool
+	msgs     chan Message
+	once     sync.Once
+}
+
+func (m *Memory) Publish(ctx context.Context, channel, payload string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return 0, ErrClosed
+	}
+	var n int64
+	for sub := range m.subs {
+		if !sub.channels[channel] {
+			continue
+		}
+		n++
+		select {
+		case sub.msgs <- Message{Channel: channel, Payload: payload}:
+		default:
+			// Buffer full, dropped
+		}
+	}
+	return n, nil
+}
+
+func (m *Memory) Subscribe(ctx context.Context, channels ...string) (Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, ErrClosed
+	}
+	sub := &memSubscription{m: m, channels: map[string]bool{}, msgs: make(chan Message, m.bufSize)}
+	for _, c := range channels {
+		sub.channels[c] = true
+	}
+	m.subs[sub] = struct{}{}
+	return sub, nil
+}
+
+func (s *memSubscription) Messages() <-chan Message {
+	return s.msgs
+}
+
+func (s *memSubscription) Close() error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	delete(s.m.subs, s)
+	s.close()
+	return nil
+}
+
+// close ends the message channel, called with the transport lock held
+func (s *memSubscription) close() {
+	s.once.Do(func() { close(s.msgs) })
+}
+
+// value returns the live value of key, called with mu held
+func (m *Memory) value(key string) (memValue, bool) {
+	v, ok := m.values[key]
+	if ok && !v.expires.IsZero() && !time.Now().Before(v.expires) {
+		delete(m.values, key)
+		return memValue{}, false
+	}
+	return v, ok
+}
+
+func expiry(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
+}
+
+func (m *Memory) Get(ctx context.Context, key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.value(key)
+	if !ok {
+		return "", ErrNil
+	}
+	return v.value, nil
+}
+
+func (m *Memory) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[key] = memValue{value: value, expires: expiry(ttl)}
+	return nil
+}
+
+func (m *Memory) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.value(key); ok {
+		return false, nil
+	}
+	m.values[key] = memValue{value: value, expires: expiry(ttl)}
+	return true, nil
+}
+
+func (m *Memory) CompareAndSwap(ctx context.Context, key, old, value string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if v, ok := m.value(key); !ok || v.value != old {
+		return false, nil
+	}
+	m.values[key] = memValue{value: value, expires: expiry(ttl)}
+	return true, nil
+}
+
+func (m *Memory) Del(ctx context.Context, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, k := range keys {
+		delete(m.values, k)
+		delete(m.lists, k)
+	}
+	return nil
+}
+
+func (m *Memory) LPush(ctx context.Context, key string, values ...string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := m.lists[key]
+	head := make([]string, 0, len(values)+len(list))
+	for i := len(values) - 1; i >= 0; i-- {
+		head = append(head, values[i])
+	}
+	m.lists[key] = append(head, list...)
+	return int64(len(m.lists[key])), nil
+}
+
+func (m *Memory) RPop(ctx context.Context, key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := m.lists[key]
+	if len(list) == 0 {
+		return "", ErrNil
+	}
+	v := list[len(list)-1]
+	m.lists[key] = list[:len(list)-1]
+	return v, nil
+}
+
+// span resolves Redis style start/stop (negative from the end) to [lo, hi)
+func span(n, start, stop int64) (int64, int64) {
+	if start < 0 {
+		start += n
+	}
+	if stop < 0 {
+		stop += n
+	}
+	start = max(start, 0)
+	stop = min(stop, n-1)
+	if start > stop {
+		return 0, 0
+	}
+	return start, stop + 1
+}
+
+func (m *Memory) LRange(ctx context.Context, key string, start, stop int64) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := m.lists[key]
+	lo, hi := span(int64(len(list)), start, stop)
+	return append([]string(nil), list[lo:hi]...), nil
+}
+
+func (m *Memory) LLen(ctx context.Context, key string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return int64(len(m.lists[key])), nil
+}
+
+func (m *Memory) LTrim(ctx context.Context, key string, start, stop int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := m.lists[key]
+	lo, hi := span(int64(len(list)), start, stop)
+	m.lists[key] = append([]string(nil), list[lo:hi]...)
+	return nil
+}
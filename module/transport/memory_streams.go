@@ -0,0 +1,302 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type memStream struct {
+	entries []StreamEntry // Ascending ID
+	last    streamID
+	groups  map[string]*memGroup
+}
+
+type memGroup struct {
+	last    streamID // Last entry delivered to the group
+	pending map[string]*memPending
+}
+
+type memPending struct {
+	consumer   string
+	delivered  time.Time
+	deliveries int64
+}
+
+// streamID is the "<ms>-<seq>" ID of a stream entry
+type streamID struct {
+	ms, seq int64
+}
+
+func (id streamID) String() string {
+	return fmt.Sprintf("%d-%d", id.ms, id.seq)
+}
+
+func (id streamID) less(o streamID) bool {
+	return id.ms < o.ms || (id.ms == o.ms && id.seq < o.seq)
+}
+
+// parseID reads an ID or one of the ends "-" / "+", a bare ms is <ms>-0
+func parseID(s string) (streamID, error) {
+	switch s {
+	case "-":
+		return streamID{}, nil
+	case "+":
+		return streamID{math.MaxInt64, math.MaxInt64}, nil
+	}
+	msPart, seqPart, hasSeq := strings.Cut(s, "-")
+	ms, err := strconv.ParseInt(msPart, 10, 64)
+	if err != nil {
+		return streamID{}, fmt.Errorf("transport: invalid stream ID %q", s)
+	}
+	var seq int64
+	if hasSeq {
+		if seq, err = strconv.ParseInt(seqPart, 10, 64); err != nil {
+			return streamID{}, fmt.Errorf("transport: invalid stream ID %q", s)
+		}
+	}
+	return streamID{ms, seq}, nil
+}
+
+var errNoGroup = errors.New("NOGROUP no such key or consumer group")
+
+// group returns the consumer group, called with mu held
+func (m *Memory) group(stream, group string) (*memStream, *memGroup, error) {
+	st, ok := m.streams[stream]
+	if !ok {
+		return nil, nil, errNoGroup
+	}
+	g, ok := st.groups[group]
+	if !ok {
+		return nil, nil, errNoGroup
+	}
+	return st, g, nil
+}
+
+// entry returns the entry with id, called with mu held
+func (st *memStream) entry(id string) (StreamEntry, bool) {
+	want, err := parseID(id)
+	if err != nil {
+		return StreamEntry{}, false
+	}
+	i := sort.Search(len(st.entries), func(i int) bool {
+		got, _ := parseID(st.entries[i].ID)
+		return !got.less(want)
+	})
+	if i < len(st.entries) && st.entries[i].ID == id {
+		return st.entries[i], true
+	}
+	return StreamEntry{}, false
+}
+
+func copyEntry(e StreamEntry) StreamEntry {
+	values := make(map[string]string, len(e.Values))
+	for k, v := range e.Values {
+		values[k] = v
+	}
+	return StreamEntry{ID: e.ID, Values: values}
+}
+
+func (m *Memory) XAdd(ctx context.Context, stream string, maxLen int64, values map[string]string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st, ok := m.streams[stream]
+	if !ok {
+		st = &memStream{groups: map[string]*memGroup{}}
+		m.streams[stream] = st
+	}
+
+	id := streamID{ms: time.Now().UnixMilli()}
+	if !st.last.less(id) {
+		id = streamID{st.last.ms, st.last.seq + 1}
+	}
+	st.last = id
+	st.entries = append(st.entries, copyEntry(StreamEntry{ID: id.String(), Values: values}))
+	if maxLen > 0 && int64(len(st.entries)) > maxLen {
+		st.entries = append([]StreamEntry(nil), st.entries[int64(len(st.entries))-maxLen:]...)
+	}
+
+	close(m.added)
+	m.added = make(chan struct{})
+	return id.String(), nil
+}
+
+func (m *Memory) XGroupCreate(ctx context.Context, stream, group, start string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st, ok := m.streams[stream]
+	if !ok {
+		st = &memStream{groups: map[string]*memGroup{}}
+		m.streams[stream] = st
+	}
+	if _, ok := st.groups[group]; ok {
+		return nil
+	}
+	last := st.last
+	if start != "$" {
+		id, err := parseID(start)
+		if err != nil {
+			return err
+		}
+		last = id
+	}
+	st.groups[group] = &memGroup{last: last, pending: map[string]*memPending{}}
+	return nil
+}
+
+func (m *Memory) XReadGroup(ctx context.Context, stream, group, consumer string, count int64, block time.Duration) ([]StreamEntry, error) {
+	deadline := time.Now().Add(block)
+	for {
+		m.mu.Lock()
+		st, g, err := m.group(stream, group)
+		if err != nil {
+			m.mu.Unlock()
+			return nil, err
+		}
+		var out []StreamEntry
+		now := time.Now()
+		for _, e := range st.entries {
+			if count > 0 && int64(len(out)) >= count {
+				break
+			}
+			id, _ := parseID(e.ID)
+			if !g.last.less(id) {
+				continue
+			}
+			g.last = id
+			g.pending[e.ID] = &memPending{consumer: consumer, delivered: now, deliveries: 1}
+			out = append(out, copyEntry(e))
+		}
+		added := m.added
+		m.mu.Unlock()
+
+		wait := time.Until(deadline)
+		if len(out) > 0 || wait <= 0 {
+			return out, nil
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-added:
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+		timer.Stop()
+	}
+}
+
+func (m *Memory) XAck(ctx context.Context, stream, group string, ids ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, g, err := m.group(stream, group)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		delete(g.pending, id)
+	}
+	return nil
+}
+
+// pendingIDs returns the pending IDs of g in ascending order
+func (g *memGroup) pendingIDs() []string {
+	ids := make([]string, 0, len(g.pending))
+	for id := range g.pending {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, _ := parseID(ids[i])
+		b, _ := parseID(ids[j])
+		return a.less(b)
+	})
+	return ids
+}
+
+func (m *Memory) XPending(ctx context.Context, stream, group string, count int64) ([]PendingEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, g, err := m.group(stream, group)
+	if err != nil {
+		return nil, err
+	}
+	var out []PendingEntry
+	now := time.Now()
+	for _, id := range g.pendingIDs() {
+		if count > 0 && int64(len(out)) >= count {
+			break
+		}
+		p := g.pending[id]
+		out = append(out, PendingEntry{ID: id, Consumer: p.consumer, Idle: now.Sub(p.delivered), Deliveries: p.deliveries})
+	}
+	return out, nil
+}
+
+func (m *Memory) XAutoClaim(ctx context.Context, stream, group, consumer string, minIdle time.Duration, count int64) ([]StreamEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st, g, err := m.group(stream, group)
+	if err != nil {
+		return nil, err
+	}
+	var out []StreamEntry
+	now := time.Now()
+	for _, id := range g.pendingIDs() {
+		if int64(len(out)) >= count {
+			break
+		}
+		p := g.pending[id]
+		if now.Sub(p.delivered) < minIdle {
+			continue
+		}
+		e, ok := st.entry(id)
+		if !ok {
+			// Trimmed away while pending, like Redis drop it
+			delete(g.pending, id)
+			continue
+		}
+		p.consumer, p.delivered = consumer, now
+		p.deliveries++
+		out = append(out, copyEntry(e))
+	}
+	return out, nil
+}
+
+func (m *Memory) XRange(ctx context.Context, stream, start, stop string, count int64) ([]StreamEntry, error) {
+	lo, err := parseID(start)
+	if err != nil {
+		return nil, err
+	}
+	hi, err := parseID(stop)
+	if err != nil {
+		return nil, err
+	}
+	// A bare ms as the upper end covers every entry of that ms
+	if stop != "+" && !strings.Contains(stop, "-") {
+		hi.seq = math.MaxInt64
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st, ok := m.streams[stream]
+	if !ok {
+		return nil, nil
+	}
+	var out []StreamEntry
+	for _, e := range st.entries {
+		if count > 0 && int64(len(out)) >= count {
+			break
+		}
+		id, _ := parseID(e.ID)
+		if id.less(lo) || hi.less(id) {
+			continue
+		}
+		out = append(out, copyEntry(e))
+	}
+	return out, nil
+}
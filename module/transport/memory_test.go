@@ -0,0 +1,153 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryKeys(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name  string
+		ttl   time.Duration
+		wait  time.Duration
+		found bool
+	}{
+		{name: "no ttl", found: true},
+		{name: "live", ttl: time.Minute, found: true},
+		{name: "expired", ttl: 20 * time.Millisecond, wait: 40 * time.Millisecond, found: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMemory(0)
+			if err := m.Set(ctx, "k", "v", tt.ttl); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(tt.wait)
+
+			v, err := m.Get(ctx, "k")
+			if tt.found && (err != nil || v != "v") {
+				t.Fatalf("Get = %q, %v", v, err)
+			}
+			if !tt.found && !errors.Is(err, ErrNil) {
+				t.Fatalf("Get of an expired key: %q, %v", v, err)
+			}
+			// SetNX only succeeds where Get finds nothing
+			created, err := m.SetNX(ctx, "k", "w", 0)
+			if err != nil || created == tt.found {
+				t.Fatalf("SetNX created %v, %v", created, err)
+			}
+		})
+	}
+}
+
+func TestMemoryCompareAndSwap(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name    string
+		set     bool // k holds "v" before the swap
+		old     string
+		swapped bool
+		want    string
+	}{
+		{name: "matches", set: true, old: "v", swapped: true, want: "w"},
+		{name: "changed", set: true, old: "x", swapped: false, want: "v"},
+		{name: "missing", set: false, old: "v", swapped: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMemory(0)
+			if tt.set {
+				if err := m.Set(ctx, "k", "v", 0); err != nil {
+					t.Fatal(err)
+				}
+			}
+			swapped, err := m.CompareAndSwap(ctx, "k", tt.old, "w", time.Minute)
+			if err != nil || swapped != tt.swapped {
+				t.Fatalf("CompareAndSwap = %v, %v, want %v", swapped, err, tt.swapped)
+			}
+			if v, _ := m.Get(ctx, "k"); v != tt.want {
+				t.Fatalf("k = %q, want %q", v, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryLists(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(0)
+	for _, v := range []string{"1", "2", "3", "4"} {
+		if _, err := m.LPush(ctx, "l", v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.LTrim(ctx, "l", 0, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		start, stop int64
+		want        []string
+	}{
+		{"all", 0, -1, []string{"4", "3", "2"}},
+		{"head", 0, 0, []string{"4"}},
+		{"tail", -2, -1, []string{"3", "2"}},
+		{"past the end", 5, 9, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := m.LRange(ctx, "l", tt.start, tt.stop)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("LRange(%d, %d) = %v, want %v", tt.start, tt.stop, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("LRange(%d, %d) = %v, want %v", tt.start, tt.stop, got, tt.want)
+				}
+			}
+		})
+	}
+	if v, err := m.RPop(ctx, "l"); err != nil || v != "2" {
+		t.Fatalf("RPop = %q, %v", v, err)
+	}
+}
+
+func TestMemoryPublish(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(1)
+	sub, err := m.Subscribe(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		channel string
+		subs    int64
+	}{
+		{"a", 1},
+		{"b", 0},
+		{"a", 1}, // Buffer full, dropped but still counted
+	}
+	for _, tt := range tests {
+		if n, err := m.Publish(ctx, tt.channel, "x"); err != nil || n != tt.subs {
+			t.Fatalf("Publish(%s) = %d, %v, want %d", tt.channel, n, err, tt.subs)
+		}
+	}
+	if msg := <-sub.Messages(); msg.Channel != "a" || msg.Payload != "x" {
+		t.Fatalf("message %+v", msg)
+	}
+	sub.Close()
+	if _, ok := <-sub.Messages(); ok {
+		t.Fatal("messages after Close")
+	}
+	m.Close()
+	if _, err := m.Subscribe(ctx, "a"); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Subscribe after Close: %v", err)
+	}
+}
@@ -0,0 +1,246 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis is the Transport over a Redis server
+type Redis struct {
+	rdb     *redis.Client
+	bufSize int
+}
+
+// NewRedis wraps a client. Subscriptions buffer bufSize messages.
+func NewRedis(rdb *redis.Client, bufSize int) *Redis {
+	if bufSize <= 0 {
+		bufSize = 1024
+	}
+	return &Redis{rdb: rdb, bufSize: bufSize}
+}
+
+// Client is the underlying client, for Redis only features
+func (r *Redis) Client() *redis.Client {
+	return r.rdb
+}
+
+func (r *Redis) Ping(ctx context.Context) error {
+	return r.rdb.Ping(ctx).Err()
+}
+
+func (r *Redis) Close() error {
+	return r.rdb.Close()
+}
+
+func (r *Redis) Publish(ctx context.Context, channel, payload string) (int64, error) {
+	return r.rdb.Publish(ctx, channel, payload).Result()
+}
+
+type redisSubscription struct {
+	ps   *redis.PubSub
+	msgs chan Message
+	done chan struct{}
+	once sync.Once
+}
+
+func (r *Redis) Subscribe(ctx context.Context, channels ...string) (Subscription, error) {
+	ps := r.rdb.Subscribe(ctx, channels...)
+	if _, err := ps.Receive(ctx); err != nil {
+		ps.Close()
+		return nil, err
+	}
+	sub := &redisSubscription{ps: ps, msgs: make(chan Message, r.bufSize), done: make(chan struct{})}
+	go func() {
+		defer close(sub.msgs)
+		for m := range ps.Channel(redis.WithChannelSize(r.bufSize)) {
+			select {
+			case sub.msgs <- Message{Channel: m.Channel, Payload: m.Payload}:
+			case <-sub.done:
+				return
+			}
+		}
+	}()
+	return sub, nil
+}
+
+func (s *redisSubscription) Messages() <-chan Message {
+	return s.msgs
+}
+
+func (s *redisSubscription) Close() error {
+	s.once.Do(func() { close(s.done) })
+	return s.ps.Close()
+}
+
+// nilErr maps redis.Nil to ErrNil
+func nilErr(err error) error {
+	if errors.Is(err, redis.Nil) {
+		return ErrNil
+	}
+	return err
+}
+
+func (r *Redis) Get(ctx context.Context, key string) (string, error) {
+	v, err := r.rdb.Get(ctx, key).Result()
+	return v, nilErr(err)
+}
+
+func (r *Redis) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	return r.rdb.Set(ctx, key, value, ttl).Err()
+}
+
+func (r *Redis) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	return r.rdb.SetNX(ctx, key, value, ttl).Result()
+}
+
+// casScript is SET key value [PX ttl] if GET key == old, atomic on the server
+var casScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+if tonumber(ARGV[3]) > 0 then
+	redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+else
+	redis.call("SET", KEYS[1], ARGV[2])
+end
+return 1
+`)
+
+func (r *Redis) CompareAndSwap(ctx context.Context, key, old, value string, ttl time.Duration) (bool, error) {
+	n, err := casScript.Run(ctx, r.rdb, []string{key}, old, value, ttl.Milliseconds()).Int()
+	return n == 1, err
+}
+
+func (r *Redis) Del(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return r.rdb.Del(ctx, keys...).Err()
+}
+
+func (r *Redis) LPush(ctx context.Context, key string, values ...string) (int64, error) {
+	args := make([]interface{}, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return r.rdb.LPush(ctx, key, args...).Result()
+}
+
+func (r *Redis) RPop(ctx context.Context, key string) (string, error) {
+	v, err := r.rdb.RPop(ctx, key).Result()
+	return v, nilErr(err)
+}
+
+func (r *Redis) LRange(ctx context.Context, key string, start, stop int64) ([]string, error) {
+	return r.rdb.LRange(ctx, key, start, stop).Result()
+}
+
+func (r *Redis) LLen(ctx context.Context, key string) (int64, error) {
+	return r.rdb.LLen(ctx, key).Result()
+}
+
+func (r *Redis) LTrim(ctx context.Context, key string, start, stop int64) error {
+	return r.rdb.LTrim(ctx, key, start, stop).Err()
+}
+
+func entries(msgs []redis.XMessage) []StreamEntry {
+	out := make([]StreamEntry, 0, len(msgs))
+	for _, m := range msgs {
+		// Deleted (trimmed) entries come back without values
+		if m.Values == nil {
+			continue
+		}
+		values := make(map[string]string, len(m.Values))
+		for k, v := range m.Values {
+			values[k] = fmt.Sprint(v)
+		}
+		out = append(out, StreamEntry{ID: m.ID, Values: values})
+	}
+	return out
+}
+
+func (r *Redis) XAdd(ctx context.Context, stream string, maxLen int64, values map[string]string) (string, error) {
+	return r.rdb.XAdd(ctx, &redis.XAddArgs{Stream: stream, MaxLen: maxLen, Approx: maxLen > 0, Values: values}).Result()
+}
+
+func (r *Redis) XGroupCreate(ctx context.Context, stream, group, start string) error {
+	err := r.rdb.XGroupCreateMkStream(ctx, stream, group, start).Err()
+	if err != nil && strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil
+	}
+	return err
+}
+
+func (r *Redis) XReadGroup(ctx context.Context, stream, group, consumer string, count int64, block time.Duration) ([]StreamEntry, error) {
+	res, err := r.rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    group,
+		Consumer: consumer,
+		Streams:  []string{stream, ">"},
+		Count:    count,
+		Block:    block,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil || len(res) == 0 {
+		return nil, err
+	}
+	return entries(res[0].Messages), nil
+}
+
+func (r *Redis) XAck(ctx context.Context, stream, group string, ids ...string) error {
+	return r.rdb.XAck(ctx, stream, group, ids...).Err()
+}
+
+func (r *Redis) XPending(ctx context.Context, stream, group string, count int64) ([]PendingEntry, error) {
+	res, err := r.rdb.XPendingExt(ctx, &redis.XPendingExtArgs{Stream: stream, Group: group, Start: "-", End: "+", Count: count}).Result()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]PendingEntry, len(res))
+	for i, p := range res {
+		out[i] = PendingEntry{ID: p.ID, Consumer: p.Consumer, Idle: p.Idle, Deliveries: p.RetryCount}
+	}
+	return out, nil
+}
+
+func (r *Redis) XAutoClaim(ctx context.Context, stream, group, consumer string, minIdle time.Duration, count int64) ([]StreamEntry, error) {
+	var out []StreamEntry
+	start := "0-0"
+	for int64(len(out)) < count {
+		msgs, next, err := r.rdb.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   stream,
+			Group:    group,
+			Consumer: consumer,
+			MinIdle:  minIdle,
+			Start:    start,
+			Count:    count - int64(len(out)),
+		}).Result()
+		if err != nil {
+			return out, err
+		}
+		out = append(out, entries(msgs)...)
+		if next == "0-0" {
+			break
+		}
+		start = next
+	}
+	return out, nil
+}
+
+func (r *Redis) XRange(ctx context.Context, stream, start, stop string, count int64) ([]StreamEntry, error) {
+	var msgs []redis.XMessage
+	var err error
+	if count > 0 {
+		msgs, err = r.rdb.XRangeN(ctx, stream, start, stop, count).Result()
+	} else {
+		msgs, err = r.rdb.XRange(ctx, stream, start, stop).Result()
+	}
+	return entries(msgs), err
+}
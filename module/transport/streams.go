@@ -0,0 +1,42 @@
+package transport
+
+import (
+	"context"
+	"time"
+)
+
+// StreamEntry is one entry of a stream
+type StreamEntry struct {
+	ID     string
+	Values map[string]string
+}
+
+// PendingEntry is an entry delivered to a consumer group but not acked
+type PendingEntry struct {
+	ID         string
+	Consumer   string
+	Idle       time.Duration // Since the last delivery
+	Deliveries int64
+}
+
+// Streams is implemented by transports with Redis Streams semantics. It is
+// separate from Transport, a caller checks for it with a type assertion.
+type Streams interface {
+	// XAdd appends values, trimming the stream to about maxLen entries (0 keeps all)
+	XAdd(ctx context.Context, stream string, maxLen int64, values map[string]string) (string, error)
+	// XGroupCreate creates the stream and group, reading from start ("$" new
+	// entries only, "0" everything). An existing group is not an error.
+	XGroupCreate(ctx context.Context, stream, group, start string) error
+	// XReadGroup reads entries never delivered to the group, waiting up to
+	// block for one. No entries and no error on timeout.
+	XReadGroup(ctx context.Context, stream, group, consumer string, count int64, block time.Duration) ([]StreamEntry, error)
+	XAck(ctx context.Context, stream, group string, ids ...string) error
+	// XPending lists up to count pending entries of the group, oldest first
+	XPending(ctx context.Context, stream, group string, count int64) ([]PendingEntry, error)
+	// XAutoClaim gives consumer up to count entries pending for at least
+	// minIdle, counting a delivery for each
+	XAutoClaim(ctx context.Context, stream, group, consumer string, minIdle time.Duration, count int64) ([]StreamEntry, error)
+	// XRange returns up to count entries with start <= ID <= stop, "-" and
+	// "+" are the ends of the stream. count 0 returns all.
+	XRange(ctx context.Context, stream, start, stop string, count int64) ([]StreamEntry, error)
+}
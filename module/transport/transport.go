@@ -0,0 +1,54 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Transport is everything the module needs from its link to the host:
+// Pub/Sub for commands, replies and events, plus the key-value and list
+// operations used by heartbeats and the dedup cache. Redis is the
+// deployment implementation, Memory runs the module in a single process.
+
+var ErrNil = errors.New("transport: no such key")
+
+// Message received on a subscribed channel
+type Message struct {
+	Channel string
+	Payload string
+}
+
+// Subscription delivers the messages of the channels it was made for
+type Subscription interface {
+	Messages() <-chan Message
+	Close() error
+}
+
+type Transport interface {
+	Ping(ctx context.Context) error
+	Close() error
+
+	// Publish returns the number of subscribers that got the message
+	Publish(ctx context.Context, channel, payload string) (int64, error)
+	// Subscribe returns once the subscription is active
+	Subscribe(ctx context.Context, channels ...string) (Subscription, error)
+
+	// Get returns ErrNil for a missing (or expired) key
+	Get(ctx context.Context, key string) (string, error)
+	// Set with ttl 0 never expires
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+	// SetNX sets key only if it does not exist and reports whether it did
+	SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error)
+	// CompareAndSwap sets key to value only if it currently holds old, in
+	// one atomic step, and reports whether it did
+	CompareAndSwap(ctx context.Context, key, old, value string, ttl time.Duration) (bool, error)
+	Del(ctx context.Context, keys ...string) error
+
+	LPush(ctx context.Context, key string, values ...string) (int64, error)
+	// RPop returns ErrNil for an empty list
+	RPop(ctx context.Context, key string) (string, error)
+	LRange(ctx context.Context, key string, start, stop int64) ([]string, error)
+	LLen(ctx context.Context, key string) (int64, error)
+	LTrim(ctx context.Context, key string, start, stop int64) error
+}